@@ -0,0 +1,180 @@
+package bus
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ledgerhq/satstack/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// birthdayDiscoveryDepth indicates the number of addresses, on each of the
+// external and internal chains, that are looked up while discovering the
+// birthday of an account.
+//
+// Since wallets use addresses in order, the first use of an account is
+// always among its first few addresses.
+const birthdayDiscoveryDepth = 20
+
+// DiscoverBirthdays finds the birthday of every account that doesn't have
+// one, and persists the discovered birthdays in the configuration file.
+//
+// The birthday of an account is the date of the first block where one of its
+// first addresses appears in a transaction output. Accounts without any
+// activity are left without a birthday, rather than dated at the current
+// tip, so that a later discovery finds their first transaction; their import
+// is still shortened by the compact block filters.
+//
+// Discovery relies on compact block filters to locate candidate blocks, which
+// are then fetched to rule out false positives. The transaction index of
+// bitcoind cannot be used instead, since it is not indexed by address.
+func (b *Bus) DiscoverBirthdays(configuration *config.WalletConfig) error {
+	if !b.BlockFilter {
+		return ErrBlockFilterDisabled
+	}
+
+	client, err := b.ClientFactory()
+	if err != nil {
+		return err
+	}
+
+	defer client.Shutdown()
+
+	var discovered int
+	for _, account := range configuration.ActiveAccounts() {
+		if account.Birthday != nil {
+			continue
+		}
+
+		birthday, err := b.discoverBirthday(client, account)
+		if errors.Is(err, ErrPrunedHistory) {
+			// The account is left without a birthday, so that its import
+			// is handled according to the prune mode.
+			warning := fmt.Sprintf("account %s: cannot discover birthday: %s; set it in lss.json",
+				account.ID(), err)
+			b.addWarning(warning)
+
+			log.WithField("prefix", "worker").Warn(warning)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if birthday == nil {
+			log.WithFields(log.Fields{
+				"prefix":     "worker",
+				"descriptor": *account.External,
+			}).Info("No activity found for account, birthday not saved")
+			continue
+		}
+
+		configuration.UpdateAccount(account.ID(), func(a *config.Account) {
+			a.SetBirthday(*birthday)
+		})
+		discovered++
+
+		log.WithFields(log.Fields{
+			"prefix":     "worker",
+			"descriptor": *account.External,
+			"birthday":   birthday.UTC().Format("2006/01/02"),
+		}).Info("Discovered account birthday")
+	}
+
+	if discovered == 0 {
+		return nil
+	}
+
+	return configuration.Save()
+}
+
+// discoverBirthday returns the time of the first block containing an output
+// to one of the first addresses of the account, or nil if there is none.
+//
+// ErrPrunedHistory is returned if the node is pruned after the BIP39 genesis,
+// since earlier activity could not be ruled out.
+func (b *Bus) discoverBirthday(client *rpcclient.Client, account config.Account) (*time.Time, error) {
+	accountDescriptors, err := descriptors(client, account)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range accountDescriptors {
+		accountDescriptors[idx].Depth = birthdayDiscoveryDepth - 1
+	}
+
+	scripts, err := b.descriptorScripts(client, accountDescriptors)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := heightAtTime(client, config.BIP0039Genesis)
+	if err != nil {
+		return nil, err
+	}
+
+	// Activity in pruned blocks cannot be ruled out, so the birthday found
+	// after them could hide earlier transactions.
+	earliest, err := pruneHeight(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if from < earliest {
+		return nil, fmt.Errorf("%w: blocks before height %d are pruned", ErrPrunedHistory, earliest)
+	}
+
+	to, err := client.GetBlockCount()
+	if err != nil {
+		return nil, err
+	}
+
+	for from <= to {
+		height, err := b.earliestFilterMatch(client, scripts, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		if height == -1 {
+			return nil, nil
+		}
+
+		hash, err := client.GetBlockHash(height)
+		if err != nil {
+			return nil, err
+		}
+
+		block, err := client.GetBlock(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if blockPaysToAny(block.Transactions, scripts) {
+			return &block.Header.Timestamp, nil
+		}
+
+		// False positive of the compact block filter.
+		from = height + 1
+	}
+
+	return nil, nil
+}
+
+// blockPaysToAny checks whether any output of the given transactions pays to
+// one of the given output scripts.
+func blockPaysToAny(txs []*wire.MsgTx, scripts [][]byte) bool {
+	for _, tx := range txs {
+		for _, txOut := range tx.TxOut {
+			for _, script := range scripts {
+				if bytes.Equal(txOut.PkScript, script) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
@@ -1,32 +1,73 @@
 package bus
 
 import (
+	"encoding/json"
+	"time"
+
+	"github.com/ledgerhq/satstack/protocol"
 	"github.com/ledgerhq/satstack/types"
 	"github.com/ledgerhq/satstack/utils"
 
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
 )
 
+// blockVerboseResult models the response of the getblock RPC with verbosity
+// set to 1.
+//
+// btcjson.GetBlockVerboseResult does not include the median time past of the
+// block, which is returned by bitcoind.
+type blockVerboseResult struct {
+	btcjson.GetBlockVerboseResult
+	MedianTime int64 `json:"mediantime"`
+}
+
+// blockHeaderVerboseResult models the response of the getblockheader RPC
+// with verbose set to true, including the fields ignored by btcjson.
+type blockHeaderVerboseResult struct {
+	btcjson.GetBlockHeaderVerboseResult
+	MedianTime int64 `json:"mediantime"`
+}
+
 func (b *Bus) GetBestBlockHash() (*chainhash.Hash, error) {
-	return b.mainClient.GetBestBlockHash()
+	return b.main().GetBestBlockHash()
 }
 
 func (b *Bus) GetBlockCount() (int64, error) {
-	return b.mainClient.GetBlockCount()
+	return b.main().GetBlockCount()
 
 }
 
 func (b *Bus) GetBlockHash(height int64) (*chainhash.Hash, error) {
-	return b.mainClient.GetBlockHash(height)
+	return b.main().GetBlockHash(height)
 }
 
+// GetBlock returns the block identified by the given hash, along with its
+// header details and the list of transaction IDs it contains.
 func (b *Bus) GetBlock(hash *chainhash.Hash) (*types.Block, error) {
-	nativeBlock, err := b.mainClient.GetBlockVerbose(hash)
+	hashJSON, err := json.Marshal(hash.String())
+	if err != nil {
+		return nil, err
+	}
+
+	verbosityJSON, err := json.Marshal(1)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := b.main().RawRequest("getblock", []json.RawMessage{
+		hashJSON, verbosityJSON,
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	var nativeBlock blockVerboseResult
+	if err := json.Unmarshal(result, &nativeBlock); err != nil {
+		return nil, err
+	}
+
 	transactions := make([]string, len(nativeBlock.Tx))
 	for idx, transaction := range nativeBlock.Tx {
 		transactions[idx] = transaction
@@ -37,11 +78,142 @@ func (b *Bus) GetBlock(hash *chainhash.Hash) (*types.Block, error) {
 		Height:       nativeBlock.Height,
 		Time:         utils.ParseUnixTimestamp(nativeBlock.Time),
 		Transactions: &transactions,
+		PreviousHash: nativeBlock.PreviousHash,
+		MerkleRoot:   nativeBlock.MerkleRoot,
+		Bits:         nativeBlock.Bits,
+		Difficulty:   nativeBlock.Difficulty,
+		Size:         nativeBlock.Size,
+		Weight:       nativeBlock.Weight,
+		MedianTime:   utils.ParseUnixTimestamp(nativeBlock.MedianTime),
 	}
 
 	return &block, nil
 }
 
+// GetBlockHeader returns the header details of the block identified by the
+// given hash. Unlike GetBlock, the returned value does not include the list
+// of transactions, nor the size and weight of the block.
+func (b *Bus) GetBlockHeader(hash *chainhash.Hash) (*types.Block, error) {
+	hashJSON, err := json.Marshal(hash.String())
+	if err != nil {
+		return nil, err
+	}
+
+	verboseJSON, err := json.Marshal(true)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := b.main().RawRequest("getblockheader", []json.RawMessage{
+		hashJSON, verboseJSON,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var header blockHeaderVerboseResult
+	if err := json.Unmarshal(result, &header); err != nil {
+		return nil, err
+	}
+
+	return &types.Block{
+		Hash:         header.Hash,
+		Height:       int64(header.Height),
+		Time:         utils.ParseUnixTimestamp(header.Time),
+		PreviousHash: header.PreviousHash,
+		MerkleRoot:   header.MerkleRoot,
+		Bits:         header.Bits,
+		Difficulty:   header.Difficulty,
+		MedianTime:   utils.ParseUnixTimestamp(header.MedianTime),
+	}, nil
+}
+
+// GetRawBlockHeader returns the hex-encoded 80-byte header of the block
+// identified by the given hash.
+func (b *Bus) GetRawBlockHeader(hash *chainhash.Hash) (string, error) {
+	header, err := b.main().GetBlockHeader(hash)
+	if err != nil {
+		return "", err
+	}
+
+	return protocol.SerializeBlockHeader(header)
+}
+
+// GetTxOutProof returns the hex-encoded merkle block proving the inclusion of
+// the given transaction in the given block.
+//
+// The block hash is required, since bitcoind cannot locate transactions in
+// the absence of a transaction index, unless they have unspent outputs.
+func (b *Bus) GetTxOutProof(txHash *chainhash.Hash, blockHash *chainhash.Hash) (string, error) {
+	txHashesJSON, err := json.Marshal([]string{txHash.String()})
+	if err != nil {
+		return "", err
+	}
+
+	blockHashJSON, err := json.Marshal(blockHash.String())
+	if err != nil {
+		return "", err
+	}
+
+	result, err := b.main().RawRequest("gettxoutproof", []json.RawMessage{
+		txHashesJSON, blockHashJSON,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var proof string
+	if err := json.Unmarshal(result, &proof); err != nil {
+		return "", err
+	}
+
+	return proof, nil
+}
+
 func (b *Bus) GetBlockChainInfo() (*btcjson.GetBlockChainInfoResult, error) {
-	return b.mainClient.GetBlockChainInfo()
+	return b.main().GetBlockChainInfo()
+}
+
+// GetHeightByTime returns the height of the first block with a timestamp at
+// or after the given time. If no such block exists, the current tip height is
+// returned.
+func (b *Bus) GetHeightByTime(t time.Time) (int64, error) {
+	return heightAtTime(b.main(), t)
+}
+
+// heightAtTime performs a binary search over the active chain to find the
+// first block with a timestamp at or after the given time.
+//
+// Block timestamps are not strictly monotonic, but are always greater than
+// the median time past of the previous 11 blocks, so the result is accurate
+// to within a couple of hours, which is within the margin applied by
+// bitcoind to rescan timestamps.
+func heightAtTime(client *rpcclient.Client, t time.Time) (int64, error) {
+	tip, err := client.GetBlockCount()
+	if err != nil {
+		return -1, err
+	}
+
+	low, high := int64(0), tip
+	for low < high {
+		mid := low + (high-low)/2
+
+		hash, err := client.GetBlockHash(mid)
+		if err != nil {
+			return -1, err
+		}
+
+		header, err := client.GetBlockHeaderVerbose(hash)
+		if err != nil {
+			return -1, err
+		}
+
+		if header.Time < t.Unix() {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+
+	return low, nil
 }
@@ -0,0 +1,140 @@
+package bus
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/btcsuite/btcd/rpcclient"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// cookieCheckInterval indicates how often the cookie file is checked for
+	// rotation.
+	cookieCheckInterval = 5 * time.Second
+
+	// clientDrainTimeout is the time given to in-flight requests of RPC
+	// clients that were replaced, before shutting them down.
+	clientDrainTimeout = time.Minute
+)
+
+// readCookie reads the RPC credentials from the cookie file of bitcoind,
+// which contains a single user:password line.
+func readCookie(path string) (string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	data = bytes.TrimSpace(data)
+
+	i := bytes.IndexByte(data, ':')
+	if i < 0 {
+		return "", "", errors.New("malformed cookie")
+	}
+
+	return string(data[:i]), string(data[i+1:]), nil
+}
+
+// monitorCookie periodically checks the cookie file of bitcoind, which is
+// recreated with new credentials whenever bitcoind restarts, and replaces
+// the RPC clients once the credentials change.
+//
+// This is a blocking operation that returns once the Bus is closed.
+func (b *Bus) monitorCookie() {
+	ticker := time.NewTicker(cookieCheckInterval)
+	defer ticker.Stop()
+
+	var lastModTime time.Time
+	if info, err := os.Stat(b.cookieFile); err == nil {
+		lastModTime = info.ModTime()
+	}
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+
+		// The cookie file is deleted while bitcoind is stopped.
+		info, err := os.Stat(b.cookieFile)
+		if err != nil || info.ModTime().Equal(lastModTime) {
+			continue
+		}
+
+		user, pass, err := readCookie(b.cookieFile)
+		if err != nil {
+			// The file may be read while bitcoind is writing it.
+			log.WithFields(log.Fields{
+				"path":  b.cookieFile,
+				"error": err,
+			}).Debug("Failed to read cookie file")
+			continue
+		}
+
+		lastModTime = info.ModTime()
+
+		b.clientsMu.RLock()
+		unchanged := b.connCfg.User == user && b.connCfg.Pass == pass
+		b.clientsMu.RUnlock()
+
+		if unchanged {
+			continue
+		}
+
+		if err := b.setCredentials(user, pass); err != nil {
+			log.WithFields(log.Fields{
+				"wallet": b.Wallet,
+				"error":  err,
+			}).Error("Failed to reconnect with the new cookie")
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"wallet": b.Wallet,
+			"path":   b.cookieFile,
+		}).Info("Cookie rotated, RPC clients reconnected")
+	}
+}
+
+// setCredentials replaces the RPC clients of the Bus with new ones, using
+// the given credentials. The replaced clients are shut down once their
+// in-flight requests had time to complete.
+func (b *Bus) setCredentials(user string, pass string) error {
+	b.clientsMu.RLock()
+	connCfg := *b.connCfg
+	b.clientsMu.RUnlock()
+
+	connCfg.User = user
+	connCfg.Pass = pass
+
+	var clients []*rpcclient.Client
+	for i := 0; i < 3; i++ {
+		client, err := rpcclient.New(&connCfg, nil)
+		if err != nil {
+			for _, c := range clients {
+				c.Shutdown()
+			}
+			return err
+		}
+
+		clients = append(clients, client)
+	}
+
+	b.clientsMu.Lock()
+	replaced := []*rpcclient.Client{b.mainClient, b.secondaryClient, b.janitorClient}
+	b.connCfg = &connCfg
+	b.mainClient, b.secondaryClient, b.janitorClient = clients[0], clients[1], clients[2]
+	b.clientsMu.Unlock()
+
+	time.AfterFunc(clientDrainTimeout, func() {
+		for _, client := range replaced {
+			client.Shutdown()
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,404 @@
+package bus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/ledgerhq/satstack/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Severity indicates how serious a Finding of the wallet doctor is.
+type Severity string
+
+const (
+	// SeverityInfo is used for findings that don't affect the transactions
+	// reported by SatStack.
+	SeverityInfo Severity = "info"
+
+	// SeverityWarning is used for findings that may cause missing
+	// transactions or slow startups.
+	SeverityWarning Severity = "warning"
+
+	// SeverityError is used for findings that cause missing transactions.
+	SeverityError Severity = "error"
+)
+
+// Remedy indicates how a Finding of the wallet doctor can be fixed.
+type Remedy string
+
+const (
+	// RemedyNone is used for findings that need no fix, or cannot be fixed
+	// by SatStack.
+	RemedyNone Remedy = ""
+
+	// RemedyRepair is used for findings fixed by importing the
+	// descriptors of the account again.
+	RemedyRepair Remedy = "repair"
+
+	// RemedyRecreate is used for findings fixed only by recreating the
+	// wallet, and importing all the accounts into it.
+	RemedyRecreate Remedy = "recreate"
+)
+
+// Finding is an issue detected by the wallet doctor.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Account  string   `json:"account,omitempty"` // ID of the account, if any
+	Message  string   `json:"message"`
+	Remedy   Remedy   `json:"remedy,omitempty"`
+}
+
+// Diagnosis is the result of the integrity check of a wallet.
+type Diagnosis struct {
+	Wallet   string    `json:"wallet"`
+	Findings []Finding `json:"findings"`
+
+	// reimport holds the descriptors to import again to fix the findings
+	// with RemedyRepair.
+	reimport []descriptor
+}
+
+// Healthy returns true if no finding of the diagnosis needs a fix.
+func (d *Diagnosis) Healthy() bool {
+	for _, finding := range d.Findings {
+		if finding.Severity != SeverityInfo {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NeedsRecreate returns true if one of the findings can only be fixed by
+// recreating the wallet.
+func (d *Diagnosis) NeedsRecreate() bool {
+	for _, finding := range d.Findings {
+		if finding.Remedy == RemedyRecreate {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *Diagnosis) add(severity Severity, account string, remedy Remedy, format string, args ...interface{}) {
+	d.Findings = append(d.Findings, Finding{
+		Severity: severity,
+		Account:  account,
+		Message:  fmt.Sprintf(format, args...),
+		Remedy:   remedy,
+	})
+}
+
+// listDescriptorsResult models the response of the listdescriptors RPC.
+type listDescriptorsResult struct {
+	WalletName  string `json:"wallet_name"`
+	Descriptors []struct {
+		Desc      string `json:"desc"`
+		Timestamp int64  `json:"timestamp"`
+		Active    bool   `json:"active"`
+		Internal  *bool  `json:"internal,omitempty"`
+		Range     []int  `json:"range,omitempty"`
+		Next      *int   `json:"next,omitempty"`
+	} `json:"descriptors"`
+}
+
+// importTimestampMargin is the margin applied by bitcoind to the timestamp
+// of imported descriptors, to account for inaccurate block times.
+const importTimestampMargin = 2 * time.Hour
+
+// Diagnose checks the integrity of the wallet against the given accounts: it
+// compares the accounts with the descriptors of the wallet, checks their
+// ranges and timestamps, and the consistency of the rescan checkpoint.
+//
+// Errors of the RPCs are returned rather than reported as findings, since a
+// timeout or a busy node does not mean that the wallet must be recreated.
+func (b *Bus) Diagnose(accounts []config.Account) (*Diagnosis, error) {
+	client, err := b.ClientFactory()
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.Shutdown()
+
+	d := &Diagnosis{Wallet: b.Wallet}
+
+	scanning, err := b.walletScanning()
+	if err != nil {
+		return nil, err
+	}
+
+	if scanning {
+		d.add(SeverityWarning, "", RemedyNone,
+			"wallet is being scanned, results may be inaccurate until the scan is complete")
+	}
+
+	result, err := client.RawRequest("listdescriptors", nil)
+	if isLegacyWalletError(err) {
+		// SatStack only imports descriptors, which a legacy wallet cannot
+		// hold, so importing them again cannot fix it.
+		d.add(SeverityError, "", RemedyRecreate, "wallet is not a descriptor wallet: %s", err)
+		return d, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var listed listDescriptorsResult
+	if err := json.Unmarshal(result, &listed); err != nil {
+		return nil, err
+	}
+
+	var scannedSince int64
+	if checkpoint, err := b.Config.LoadRescanConf(b.Wallet); err == nil {
+		scannedSince = checkpoint.ScannedSince
+	}
+
+	// Index the descriptors of the wallet by their normalized form, and
+	// mark them as they are matched with an account.
+	imported := make(map[string]int)
+	matched := make(map[string]bool)
+	for idx, desc := range listed.Descriptors {
+		imported[normalizeDescriptor(desc.Desc)] = idx
+	}
+
+	for _, account := range accounts {
+		if account.Removed {
+			continue
+		}
+
+		descs, err := descriptors(client, account)
+		if err != nil {
+			d.add(SeverityError, account.ID(), RemedyNone, "%s", err)
+			continue
+		}
+
+		var reimport bool
+		for _, desc := range descs {
+			key := normalizeDescriptor(desc.Value)
+
+			idx, ok := imported[key]
+			if !ok {
+				d.add(SeverityError, account.ID(), RemedyRepair,
+					"descriptor %s is missing from the wallet", desc.Value)
+				reimport = true
+				continue
+			}
+
+			matched[key] = true
+			walletDesc := listed.Descriptors[idx]
+
+			if len(walletDesc.Range) == 2 && walletDesc.Range[1] < desc.Depth {
+				d.add(SeverityError, account.ID(), RemedyRepair,
+					"descriptor %s is imported up to index %d, expected %d",
+					desc.Value, walletDesc.Range[1], desc.Depth)
+				reimport = true
+			}
+
+			// The import timestamp can only be checked against a known
+			// birthday, as it may otherwise have been refined using the
+			// compact block filters.
+			if account.Birthday == nil {
+				continue
+			}
+
+			age, err := b.checkPrunedAge(client, desc.Age, fmt.Sprintf("account %s", account.ID()))
+			if err != nil {
+				d.add(SeverityWarning, account.ID(), RemedyNone, "%s", err)
+				continue
+			}
+
+			// Descriptors imported into a new wallet have the time of the
+			// import as timestamp, but the wallet was rescanned since the
+			// earliest birthday afterwards.
+			timestamp := time.Unix(walletDesc.Timestamp, 0)
+			if scannedSince > 0 && scannedSince <= int64(age) {
+				timestamp = time.Unix(scannedSince, 0)
+			}
+
+			if timestamp.After(time.Unix(int64(age), 0).Add(importTimestampMargin)) {
+				d.add(SeverityError, account.ID(), RemedyRepair,
+					"descriptor %s was scanned from %s, after the account birthday %s",
+					desc.Value, timestamp.UTC().Format(time.RFC3339),
+					account.Birthday.Format("2006/01/02"))
+				reimport = true
+			}
+		}
+
+		if reimport {
+			if err := b.addReimport(client, d, account, descs); err != nil {
+				d.add(SeverityError, account.ID(), RemedyNone, "cannot import again: %s", err)
+			}
+		}
+	}
+
+	for _, desc := range listed.Descriptors {
+		if !matched[normalizeDescriptor(desc.Desc)] {
+			d.add(SeverityInfo, "", RemedyNone,
+				"descriptor %s does not belong to any active account", desc.Desc)
+		}
+	}
+
+	b.checkRescanCheckpoint(client, d, len(listed.Descriptors) > 0)
+
+	return d, nil
+}
+
+// addReimport schedules the import of the given descriptors of an account,
+// to fix the findings of the diagnosis.
+func (b *Bus) addReimport(client *rpcclient.Client, d *Diagnosis,
+	account config.Account, descs []descriptor) error {
+	for _, desc := range descs {
+		age, err := b.checkPrunedAge(client, desc.Age, fmt.Sprintf("account %s", account.ID()))
+		if err != nil {
+			return err
+		}
+
+		desc.Age = age
+		d.reimport = append(d.reimport, desc)
+	}
+
+	return nil
+}
+
+// checkRescanCheckpoint checks the consistency of the rescan checkpoint of
+// the wallet with the chain and the wallet descriptors.
+func (b *Bus) checkRescanCheckpoint(client *rpcclient.Client, d *Diagnosis, hasDescriptors bool) {
+	checkpoint, err := b.Config.LoadRescanConf(b.Wallet)
+	if err != nil {
+		if hasDescriptors {
+			d.add(SeverityWarning, "", RemedyNone,
+				"no rescan checkpoint found, all descriptors will be imported again at startup")
+		}
+		return
+	}
+
+	if !hasDescriptors {
+		d.add(SeverityError, "", RemedyRepair,
+			"rescan checkpoint at block %d found, but the wallet has no descriptors",
+			checkpoint.LastBlock)
+		return
+	}
+
+	height, err := client.GetBlockCount()
+	if err != nil {
+		d.add(SeverityWarning, "", RemedyNone, "failed to get block count: %s", err)
+		return
+	}
+
+	if checkpoint.LastBlock > height {
+		d.add(SeverityError, "", RemedyRepair,
+			"rescan checkpoint at block %d is ahead of the chain tip at block %d",
+			checkpoint.LastBlock, height)
+	}
+}
+
+// Repair fixes the findings of the diagnosis that can be fixed by importing
+// descriptors again, and updates the rescan checkpoint. This is a blocking
+// operation, as the import triggers a rescan of the wallet.
+func (b *Bus) Repair(d *Diagnosis) error {
+	if len(d.reimport) > 0 {
+		client, err := b.ClientFactory()
+		if err != nil {
+			return err
+		}
+
+		defer client.Shutdown()
+
+		if err := ImportDescriptors(client, d.reimport); err != nil {
+			return err
+		}
+	}
+
+	return b.DumpLatestRescanTime()
+}
+
+// RecreateWallet replaces the wallet of the Bus with a new blank one, and
+// imports the given accounts into it. This is a blocking operation, as the
+// import triggers a rescan of the wallet.
+//
+// Since bitcoind cannot delete a wallet, the directory of the old wallet,
+// located in walletDir, is renamed so that it can be inspected or restored
+// later. SatStack must therefore run on the same machine as bitcoind.
+func (b *Bus) RecreateWallet(walletDir string, accounts []config.Account) error {
+	walletPath := filepath.Join(walletDir, b.Wallet)
+	if _, err := os.Stat(walletPath); err != nil {
+		return fmt.Errorf("%s: %w", ErrWalletNotFound, err)
+	}
+
+	if err := b.main().UnloadWallet(nil); err != nil {
+		log.WithFields(log.Fields{
+			"wallet": b.Wallet,
+			"error":  err,
+		}).Warn("Unable to unload wallet")
+	}
+
+	backupPath := fmt.Sprintf("%s.corrupt-%d", walletPath, time.Now().Unix())
+	if err := os.Rename(walletPath, backupPath); err != nil {
+		return fmt.Errorf("%s: %w", ErrCreateWallet, err)
+	}
+
+	log.WithFields(log.Fields{
+		"wallet": b.Wallet,
+		"backup": backupPath,
+	}).Info("Moved old wallet")
+
+	created, err := loadOrCreateWallet(b.main(), b.Wallet)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ErrCreateWallet, err)
+	}
+
+	if !created {
+		return ErrCreateWallet
+	}
+
+	b.isNewWallet = true
+
+	if err := b.ImportAccounts(accounts); err != nil {
+		return err
+	}
+
+	return b.DumpLatestRescanTime()
+}
+
+// WalletDir returns the default directory of the wallets of the connected
+// bitcoind, in the given data directory.
+func (b *Bus) WalletDir(dataDir string) string {
+	return filepath.Join(config.ChainDataDir(dataDir, b.Chain), "wallets")
+}
+
+// isLegacyWalletError checks whether the given error was returned by a wallet
+// RPC that is not available for legacy, non-descriptor wallets.
+func isLegacyWalletError(err error) bool {
+	var rpcErr *btcjson.RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+
+	return rpcErr.Code == btcjson.ErrRPCWallet && strings.Contains(rpcErr.Message, "non-descriptor")
+}
+
+// IsWalletNotLoaded checks whether the given error was returned by a wallet
+// RPC because the wallet does not exist, or is not loaded in bitcoind.
+func IsWalletNotLoaded(err error) bool {
+	var rpcErr *btcjson.RPCError
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+
+	return rpcErr.Code == btcjson.ErrRPCWalletNotFound
+}
+
+// normalizeDescriptor returns the given descriptor without checksum, and
+// with hardened derivation steps marked with h, so that descriptors returned
+// by different RPCs can be compared.
+func normalizeDescriptor(desc string) string {
+	return strings.ReplaceAll(strings.Split(desc, "#")[0], "'", "h")
+}
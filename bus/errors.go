@@ -7,6 +7,14 @@ var (
 	// was not successful. Use this error during sanity checks.
 	ErrBitcoindUnreachable = errors.New("bitcoind unreachable")
 
+	// ErrReadCookie indicates that the cookie file of bitcoind could not be
+	// read, to authenticate RPC calls.
+	ErrReadCookie = errors.New("failed to read cookie file")
+
+	// ErrRescanAborted indicates that a rescan was aborted, between two
+	// chunks of blocks.
+	ErrRescanAborted = errors.New("rescan aborted")
+
 	// ErrWalletDisabled indicates that wallet features have been disabled on
 	// the connected Bitcoin node. SatStack relies on wallet RPCs to function.
 	ErrWalletDisabled = errors.New("bitcoind wallet is disabled")
@@ -19,6 +27,10 @@ var (
 	// successful.
 	ErrLoadWallet = errors.New("failed to load wallet")
 
+	// ErrWalletNotFound indicates that the files of the wallet could not be
+	// found on disk.
+	ErrWalletNotFound = errors.New("wallet not found")
+
 	// ErrUnsupportedBitcoindVersion indicates that the connected bitcoind node
 	// has a version that is not supported by SatStack.
 	ErrUnsupportedBitcoindVersion = errors.New("unsupported bitcoind version")
@@ -48,6 +60,42 @@ var (
 	// should not be ignored silently.
 	ErrFailedToDetectBlockFilter = errors.New("failed to detect block filter")
 
+	// ErrBlockFilterDisabled indicates that a compact block filter was
+	// requested, but the connected Bitcoin node does not have a block filter
+	// index (enabled by option blockfilterindex=1 in bitcoin.conf).
+	ErrBlockFilterDisabled = errors.New("block filter index is disabled")
+
+	// ErrUnknownDescriptors indicates that the wallet has descriptors, or
+	// ranges of descriptors, that are not in the account configuration.
+	ErrUnknownDescriptors = errors.New("wallet has descriptors not in the configuration")
+
+	// ErrJobNotFound indicates that no job with the requested ID exists.
+	ErrJobNotFound = errors.New("job not found")
+
+	// ErrJobNotCancellable indicates that the requested job is not running,
+	// or is of a kind that cannot be cancelled.
+	ErrJobNotCancellable = errors.New("job cannot be cancelled")
+
+	// ErrJobCancelled indicates that a job was cancelled before it could
+	// complete.
+	ErrJobCancelled = errors.New("job cancelled")
+
+	// ErrInvalidTransition indicates an attempt to change the ScanState of the
+	// Bus to a state that cannot be reached from the current one.
+	ErrInvalidTransition = errors.New("invalid scan state transition")
+
+	// ErrBusClosed indicates that an operation was interrupted because the
+	// Bus was closed.
+	ErrBusClosed = errors.New("bus closed")
+
+	// ErrScanInProgress indicates that an operation scanning the wallet
+	// was refused, because SatStack is not ready or another scan is running.
+	ErrScanInProgress = errors.New("scan already in progress")
+
+	// ErrPrunedHistory indicates that an import or a rescan would need blocks
+	// that have been pruned by the connected Bitcoin node.
+	ErrPrunedHistory = errors.New("pruned history")
+
 	// ErrInvalidDescriptor indicates that a malformed descriptor was
 	// encountered.
 	ErrInvalidDescriptor = errors.New("invalid descriptor")
@@ -0,0 +1,300 @@
+package bus
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/gcs"
+	"github.com/btcsuite/btcd/btcutil/gcs/builder"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/ledgerhq/satstack/config"
+	"github.com/patrickmn/go-cache"
+	log "github.com/sirupsen/logrus"
+)
+
+// filterCacheExpiration indicates how long a compact block filter is kept in
+// the filter cache after it was last fetched from bitcoind.
+//
+// Filters are immutable for a given block hash, so the expiration merely
+// bounds the memory used by the cache.
+const filterCacheExpiration = time.Hour
+
+// GetBlockFilter returns the BIP-0158 basic compact filter of the block
+// identified by the given hash, along with the corresponding BIP-0157 filter
+// header.
+//
+// Results are cached by block hash, since light clients typically request
+// the same filters several times while scanning. The scans of SatStack
+// itself do not use the cache.
+func (b *Bus) GetBlockFilter(hash *chainhash.Hash) (*btcjson.GetBlockFilterResult, error) {
+	if !b.BlockFilter {
+		return nil, ErrBlockFilterDisabled
+	}
+
+	if filter, found := b.filterCache.Get(hash.String()); found {
+		return filter.(*btcjson.GetBlockFilterResult), nil
+	}
+
+	filter, err := b.main().GetBlockFilter(*hash, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b.filterCache.Set(hash.String(), filter, cache.DefaultExpiration)
+
+	return filter, nil
+}
+
+// GetBlockFilterHeader returns the BIP-0157 filter header of the block
+// identified by the given hash.
+//
+// bitcoind has no RPC returning the filter header alone, so the filter is
+// still fetched, but only the header is kept in the filter cache. This keeps
+// the cache small when clients download the filter header chain.
+func (b *Bus) GetBlockFilterHeader(hash *chainhash.Hash) (string, error) {
+	if !b.BlockFilter {
+		return "", ErrBlockFilterDisabled
+	}
+
+	if filter, found := b.filterCache.Get(hash.String()); found {
+		return filter.(*btcjson.GetBlockFilterResult).Header, nil
+	}
+
+	key := "header:" + hash.String()
+	if header, found := b.filterCache.Get(key); found {
+		return header.(string), nil
+	}
+
+	filter, err := b.main().GetBlockFilter(*hash, nil)
+	if err != nil {
+		return "", err
+	}
+
+	b.filterCache.Set(key, filter.Header, cache.DefaultExpiration)
+
+	return filter.Header, nil
+}
+
+// filterScanLogInterval indicates the number of blocks after which progress
+// of a compact filter scan is logged.
+const filterScanLogInterval = 10000
+
+// descriptorScripts derives the addresses of the given descriptors, from
+// index 0 up to their depth, and returns the corresponding output scripts.
+func (b *Bus) descriptorScripts(client *rpcclient.Client, descs []descriptor) ([][]byte, error) {
+	var scripts [][]byte
+
+	for _, desc := range descs {
+		addresses, err := client.DeriveAddresses(desc.Value,
+			&btcjson.DescriptorRange{Value: []int{0, desc.Depth}})
+		if err != nil {
+			return nil, fmt.Errorf("%s (%s): %w", ErrDeriveAddress, desc.Value, err)
+		}
+
+		for _, address := range *addresses {
+			decoded, err := btcutil.DecodeAddress(address, b.Params)
+			if err != nil {
+				return nil, fmt.Errorf("%s (%s): %w", ErrDeriveAddress, address, err)
+			}
+
+			script, err := txscript.PayToAddrScript(decoded)
+			if err != nil {
+				return nil, fmt.Errorf("%s (%s): %w", ErrDeriveAddress, address, err)
+			}
+
+			scripts = append(scripts, script)
+		}
+	}
+
+	return scripts, nil
+}
+
+// earliestFilterMatch scans the compact block filters between the given
+// heights, both inclusive, and returns the height of the first block whose
+// filter matches any of the given output scripts.
+//
+// Compact block filters have no false negatives, so no block before the
+// returned height can contain any of the scripts. If no filter matches, -1
+// is returned.
+func (b *Bus) earliestFilterMatch(client *rpcclient.Client, scripts [][]byte,
+	from int64, to int64) (int64, error) {
+	if !b.BlockFilter {
+		return -1, ErrBlockFilterDisabled
+	}
+
+	if len(scripts) == 0 {
+		return -1, nil
+	}
+
+	for height := from; height <= to; height++ {
+		if (height-from)%filterScanLogInterval == 0 {
+			log.WithFields(log.Fields{
+				"prefix": "worker",
+				"height": fmt.Sprintf("%d/%d", height, to),
+			}).Info("Scanning compact block filters")
+		}
+
+		hash, err := client.GetBlockHash(height)
+		if err != nil {
+			return -1, err
+		}
+
+		matched, err := filterMatchAny(client, hash, scripts)
+		if err != nil {
+			return -1, err
+		}
+
+		if matched {
+			return height, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// filterMatchAny checks whether the compact filter of the block identified by
+// the given hash matches any of the given output scripts.
+//
+// The filter is fetched with the given client, bypassing the filter cache:
+// scans cover most of the chain, and would otherwise fill the cache with
+// filters that are not requested again.
+func filterMatchAny(client *rpcclient.Client, hash *chainhash.Hash, scripts [][]byte) (bool, error) {
+	result, err := client.GetBlockFilter(*hash, nil)
+	if err != nil {
+		return false, err
+	}
+
+	rawFilter, err := hex.DecodeString(result.Filter)
+	if err != nil {
+		return false, err
+	}
+
+	filter, err := gcs.FromNBytes(builder.DefaultP, builder.DefaultM, rawFilter)
+	if err != nil {
+		return false, err
+	}
+
+	return filter.MatchAny(builder.DeriveKey(hash), scripts)
+}
+
+// prescanAge uses compact block filters to find the earliest block that may
+// contain activity for the given descriptors, and returns a timestamp that
+// can be used as the import timestamp of the descriptors.
+//
+// The scan starts from the block at the given timestamp. If no filter
+// matches, the time of the current tip is returned, so that bitcoind skips
+// the rescan altogether.
+func (b *Bus) prescanAge(client *rpcclient.Client, descs []descriptor, since uint32) (uint32, error) {
+	scripts, err := b.descriptorScripts(client, descs)
+	if err != nil {
+		return 0, err
+	}
+
+	from, err := heightAtTime(client, time.Unix(int64(since), 0))
+	if err != nil {
+		return 0, err
+	}
+
+	to, err := client.GetBlockCount()
+	if err != nil {
+		return 0, err
+	}
+
+	height, err := b.earliestFilterMatch(client, scripts, from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	if height == -1 {
+		height = to
+	}
+
+	hash, err := client.GetBlockHash(height)
+	if err != nil {
+		return 0, err
+	}
+
+	header, err := client.GetBlockHeaderVerbose(hash)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(header.Time), nil
+}
+
+// filterRescanStart scans the compact block filters between the given
+// heights for the scripts of all the accounts, and returns the height from
+// which the wallet should be rescanned, or -1 if no rescan is needed.
+//
+// In case of error, the original start height is returned along with the
+// error, so that the caller can fall back to a regular rescan.
+func (b *Bus) filterRescanStart(accounts []config.Account, from int64, to int64) (int64, error) {
+	client, err := b.ClientFactory()
+	if err != nil {
+		return from, err
+	}
+
+	defer client.Shutdown()
+
+	var allDescriptors []descriptor
+	for _, account := range accounts {
+		accountDescriptors, err := descriptors(client, account)
+		if err != nil {
+			return from, err
+		}
+
+		allDescriptors = append(allDescriptors, accountDescriptors...)
+	}
+
+	if err := checkWalletDescriptors(client, allDescriptors); err != nil {
+		return from, err
+	}
+
+	scripts, err := b.descriptorScripts(client, allDescriptors)
+	if err != nil {
+		return from, err
+	}
+
+	height, err := b.earliestFilterMatch(client, scripts, from, to)
+	if err != nil {
+		return from, err
+	}
+
+	return height, nil
+}
+
+// checkWalletDescriptors returns ErrUnknownDescriptors if the wallet has a
+// descriptor that is not among the given ones, or with a larger range, such
+// as a descriptor imported by hand. The compact block filters cannot be used
+// to skip blocks for such descriptors, whose scripts are unknown.
+func checkWalletDescriptors(client *rpcclient.Client, descs []descriptor) error {
+	result, err := client.RawRequest("listdescriptors", nil)
+	if err != nil {
+		return err
+	}
+
+	var listed listDescriptorsResult
+	if err := json.Unmarshal(result, &listed); err != nil {
+		return err
+	}
+
+	depths := make(map[string]int, len(descs))
+	for _, desc := range descs {
+		depths[normalizeDescriptor(desc.Value)] = desc.Depth
+	}
+
+	for _, walletDesc := range listed.Descriptors {
+		depth, ok := depths[normalizeDescriptor(walletDesc.Desc)]
+		if !ok || (len(walletDesc.Range) == 2 && walletDesc.Range[1] > depth) {
+			return fmt.Errorf("%s: %s", ErrUnknownDescriptors, walletDesc.Desc)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package bus
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/ledgerhq/satstack/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// gapLimit indicates the minimum number of unused addresses that must
+	// remain imported after the highest used address of a descriptor. When
+	// fewer addresses remain, the imported range is extended.
+	gapLimit = 20
+
+	// gapLimitCheckInterval indicates how often the wallet transactions are
+	// checked against the imported ranges of the descriptors.
+	gapLimitCheckInterval = 10 * time.Minute
+)
+
+// MonitorGapLimit periodically checks the highest used address index of
+// every account descriptor, and extends the imported range of the account
+// when usage gets within gapLimit of its depth. The new depth is persisted
+// in the configuration file.
+//
+// This is a blocking operation that returns once the Bus is closed.
+func (b *Bus) MonitorGapLimit(configuration *config.WalletConfig) {
+	ticker := time.NewTicker(gapLimitCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+
+		// Extending a range triggers a rescan, which must not overlap with
+		// an ongoing one.
+		if b.State.Current() != StateReady {
+			continue
+		}
+
+		if err := b.checkGapLimit(configuration); err != nil {
+			log.WithFields(log.Fields{
+				"prefix": "worker",
+				"error":  err,
+			}).Error("Failed to check gap limit")
+		}
+	}
+}
+
+// checkGapLimit performs a single pass of the gap limit monitor.
+func (b *Bus) checkGapLimit(configuration *config.WalletConfig) error {
+	client, err := b.ClientFactory()
+	if err != nil {
+		return err
+	}
+
+	defer client.Shutdown()
+
+	txs, err := b.ListTransactions(nil)
+	if err != nil {
+		return err
+	}
+
+	usedAddresses := make(map[string]bool, len(txs))
+	for _, tx := range txs {
+		usedAddresses[tx.Address] = true
+	}
+
+	var extended int
+	for _, account := range configuration.ActiveAccounts() {
+		accountDescriptors, err := descriptors(client, account)
+		if err != nil {
+			return err
+		}
+
+		depth := accountDescriptors[0].Depth
+
+		highest := -1
+		for _, desc := range accountDescriptors {
+			used, err := highestUsedIndex(client, desc, usedAddresses)
+			if err != nil {
+				return err
+			}
+
+			if used > highest {
+				highest = used
+			}
+		}
+
+		if highest < depth-gapLimit || depth >= config.MaxDepth {
+			continue
+		}
+
+		newDepth := depth + defaultAccountDepth
+		if newDepth > config.MaxDepth {
+			newDepth = config.MaxDepth
+		}
+		for idx := range accountDescriptors {
+			accountDescriptors[idx].Depth = newDepth
+		}
+
+		log.WithFields(log.Fields{
+			"prefix":       "worker",
+			"descriptor":   *account.External,
+			"highestIndex": highest,
+			"depth":        newDepth,
+		}).Info("Extending imported range of account")
+
+		err = b.Jobs.Run(JobImport, []string{account.ID()}, func() error {
+			return b.Scan(StateImporting, "gap limit reached", func() error {
+				return ImportDescriptors(client, accountDescriptors)
+			})
+		})
+
+		if err != nil {
+			return err
+		}
+
+		configuration.UpdateAccount(account.ID(), func(a *config.Account) {
+			a.Depth = &newDepth
+		})
+		extended++
+	}
+
+	if extended == 0 {
+		return nil
+	}
+
+	return configuration.Save()
+}
+
+// highestUsedIndex returns the index of the highest address of the given
+// descriptor, within its imported range, that is present in the set of used
+// addresses. If none of the addresses are used, -1 is returned.
+func highestUsedIndex(client *rpcclient.Client, desc descriptor,
+	usedAddresses map[string]bool) (int, error) {
+	addresses, err := client.DeriveAddresses(desc.Value,
+		&btcjson.DescriptorRange{Value: []int{0, desc.Depth}})
+	if err != nil {
+		return -1, err
+	}
+
+	for idx := len(*addresses) - 1; idx >= 0; idx-- {
+		if usedAddresses[(*addresses)[idx]] {
+			return idx, nil
+		}
+	}
+
+	return -1, nil
+}
@@ -4,9 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/btcsuite/btcd/chaincfg"
@@ -37,27 +38,25 @@ const (
 	// supported by SatStack.
 	minSupportedBitcoindVersion = 220000
 
-	// walletName indicates the name of the wallet created by SatStack in
-	// bitcoind's wallet.
-	walletName = "satstack"
-
 	errDuplicateWalletLoadMsg    = "Duplicate -wallet filename specified."
 	errWalletAlreadyLoadedMsgOld = "Wallet file verification failed. Refusing to load database. Data file"
 	// Cores Responds changes so adding the new one but keeping the old for backwards compatibility
 	errWalletAlreadyLoadedMsgNew = "Wallet file verification failed. SQLiteDatabase: Unable to obtain an exclusive lock on the database"
 )
 
-var (
-	// A new wallet needs to import the descriptors therefore
-	// we need this information when starting the import worker
-	isNewWallet bool
-)
-
 // Bus represents a transport allowing access to Bitcoin RPC methods.
 //
 // It maintains a pool of btcd rpcclient objects in a buffered channel to allow
 // concurrent invocation of RPC methods.
 type Bus struct {
+	// Wallet is the name of the bitcoind wallet holding the descriptors of
+	// the accounts served by this Bus.
+	Wallet string
+
+	// A new wallet needs to import the descriptors therefore
+	// we need this information when starting the import worker
+	isNewWallet bool
+
 	// Informational fields
 	Chain       string
 	Pruned      bool
@@ -65,9 +64,32 @@ type Bus struct {
 	BlockFilter bool
 	Currency    Currency // Based on Chain value, for interoperability with libcore
 
+	// PruneMode indicates how to handle scans that need pruned blocks.
+	PruneMode PruneMode
+
+	// Config is the configuration of SatStack, which locates the rescan
+	// checkpoint of the wallet.
+	Config *config.Configuration
+
+	// CheckpointLock, if set, is the lock on the rescan checkpoint of the
+	// wallet, released once the Bus is closed.
+	CheckpointLock *config.Lock
+
+	// Warnings to report in the status endpoint, guarded by warningsMu.
+	warnings   []string
+	warningsMu sync.Mutex
+
+	// Jobs runs and keeps track of long-running operations, such as imports
+	// and rescans.
+	Jobs *JobManager
+
 	// Thread-safe Bus cache, to query results typically by hash
 	Cache *cache.Cache
 
+	// Thread-safe cache of compact block filters and filter headers, keyed
+	// by block hash. Entries expire an hour after they were last fetched.
+	filterCache *cache.Cache
+
 	// Config to use for creating new connections on-demand.
 	connCfg *rpcclient.ConnConfig
 
@@ -81,16 +103,32 @@ type Bus struct {
 	// RPC client reserved for performing RPC-based cleanups.
 	janitorClient *rpcclient.Client
 
+	// clientsMu guards connCfg and the RPC clients, which are replaced when
+	// the credentials of bitcoind change. Use the main, secondary and
+	// janitor accessors.
+	clientsMu sync.RWMutex
+
+	// cookieFile is the path of the cookie file of bitcoind, if the
+	// credentials are read from it.
+	cookieFile string
+
 	// btcd network params
 	Params *chaincfg.Params
 
-	// IsPendingScan is a boolean field to indicate if satstack is currently
-	// waiting for descriptors to be scanned or other initial operations like "running the numbers"
-	// before the bridge can operate correctly
+	// done is closed when the Bus is closed, to stop background monitors.
+	done chan struct{}
+
+	// rescanAborted is set by AbortRescan, to stop chunked rescans, and
+	// cleared by the JobManager when the next job starts scanning.
+	rescanAborted atomic.Bool
+
+	// State indicates whether satstack is currently waiting for descriptors
+	// to be scanned or other initial operations like "running the numbers"
+	// before the bridge can operate correctly.
 	//
-	// This value can be exported for use by other packages to avoid making
-	// explorer requests before satstack is able to serve them.
-	IsPendingScan bool
+	// It can be used by other packages to avoid making explorer requests
+	// before satstack is able to serve them.
+	State *ScanStateMachine
 }
 
 type descriptor struct {
@@ -99,14 +137,61 @@ type descriptor struct {
 	Age   uint32
 }
 
-// New initializes a Bus struct that embeds a btcd RPC client.
-func New(host string, user string, pass string, proxy string, noTLS bool, unloadWallet bool) (*Bus, error) {
+// New initializes a Bus struct that embeds a btcd RPC client, connected to
+// the given bitcoind wallet. The wallet is loaded, or created if it does not
+// exist yet.
+func New(host string, user string, pass string, cookieFile string, proxy string, noTLS bool,
+	wallet string) (*Bus, error) {
+	b, err := Dial(host, user, pass, cookieFile, proxy, noTLS, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	b.isNewWallet, err = loadOrCreateWallet(b.main(), wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.isNewWallet {
+		log.WithFields(log.Fields{
+			"wallet": wallet,
+		}).Info("Created new wallet")
+	} else {
+		log.WithFields(log.Fields{
+			"wallet": wallet,
+		}).Info("Loaded existing wallet")
+	}
+
+	return b, nil
+}
+
+// Dial initializes a Bus struct like New, without loading the wallet. It is
+// meant for operations on the wallet itself, such as unloading it.
+//
+// If the user and password are empty, the credentials are read from the
+// cookie file of bitcoind, and read again whenever bitcoind rotates it.
+func Dial(host string, user string, pass string, cookieFile string, proxy string, noTLS bool,
+	wallet string) (*Bus, error) {
 	log.Info("Warming up...")
 
+	// Explicit credentials take precedence over the cookie file.
+	if user != "" || pass != "" {
+		cookieFile = ""
+	}
+
+	if cookieFile != "" {
+		var err error
+		if user, pass, err = readCookie(cookieFile); err != nil {
+			return nil, fmt.Errorf("%s: %w", ErrReadCookie, err)
+		}
+
+		log.WithField("path", cookieFile).Info("Using cookie authentication")
+	}
+
 	// Prepare the connection config to initialize the rpcclient.Client
 	// pool with.
 	connCfg := &rpcclient.ConnConfig{
-		Host:         fmt.Sprintf("%s/wallet/%s", host, walletName),
+		Host:         fmt.Sprintf("%s/wallet/%s", host, wallet),
 		User:         user,
 		Pass:         pass,
 		Proxy:        proxy,
@@ -159,48 +244,35 @@ func New(host string, user string, pass string, proxy string, noTLS bool, unload
 		return nil, err
 	}
 
-	if unloadWallet {
-		if err = mainClient.UnloadWallet(nil); err != nil {
-			return nil, err
-		}
-
-		log.Info("Unload wallet: done")
-		os.Exit(1)
-	}
-
-	isNewWallet, err = loadOrCreateWallet(mainClient)
-	if err != nil {
-		return nil, err
-	}
-
-	if isNewWallet {
-		log.WithFields(log.Fields{
-			"wallet": walletName,
-		}).Info("Created new wallet")
-	} else {
-		log.WithFields(log.Fields{
-			"wallet": walletName,
-		}).Info("Loaded existing wallet")
-	}
-
 	params, err := ChainParams(info.Chain)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get chain params: %w", err)
 	}
 
 	b := &Bus{
+		Wallet:          wallet,
+		cookieFile:      cookieFile,
 		connCfg:         connCfg,
 		mainClient:      mainClient,
 		secondaryClient: secondaryClient,
 		janitorClient:   janitorClient,
 		Pruned:          info.Pruned,
+		PruneMode:       PruneRefuse,
 		Chain:           info.Chain,
 		BlockFilter:     blockFilter,
 		TxIndex:         txIndex,
 		Currency:        currency,
 		Cache:           nil, // Disabled by default
+		filterCache:     cache.New(filterCacheExpiration, filterCacheExpiration),
+		done:            make(chan struct{}),
 		Params:          params,
-		IsPendingScan:   true,
+		State:           newScanStateMachine(),
+	}
+
+	b.Jobs = newJobManager(b)
+
+	if cookieFile != "" {
+		go b.monitorCookie()
 	}
 
 	return b, nil
@@ -212,16 +284,18 @@ func New(host string, user string, pass string, proxy string, noTLS bool, unload
 // The cleanup must be performed within a timeout set by the passed context,
 // to prevent hanging on connections indefinitely held by bitcoind.
 func (b *Bus) Close(ctx context.Context) {
+	close(b.done)
+
 	done := make(chan bool)
 
 	go func() {
-		b.mainClient.Shutdown()
-		b.secondaryClient.Shutdown()
+		b.main().Shutdown()
+		b.secondary().Shutdown()
 
 		// Only unload wallet if we are not in a pending scan
 		// otherwise the nuclear timeout corrupts the wallet state
-		if !b.IsPendingScan {
-			b.UnloadWallet()
+		if !b.State.Pending() {
+			_ = b.UnloadWallet()
 		}
 		done <- true
 	}()
@@ -230,17 +304,54 @@ func (b *Bus) Close(ctx context.Context) {
 	case <-ctx.Done():
 		// Chernobyl nuclear disaster.
 
-		b.janitorClient.Shutdown()
+		b.janitor().Shutdown()
 		log.WithField("error", ctx.Err()).Fatal("Shutdown server: force")
 	case <-done:
 		// The control rods have been lowered into the nuclear core, and the
 		// chain reaction has gracefully stopped.
 	}
 
+	if b.CheckpointLock != nil {
+		if err := b.CheckpointLock.Unlock(); err != nil {
+			log.WithFields(log.Fields{
+				"wallet": b.Wallet,
+				"error":  err,
+			}).Error("Failed to release rescan checkpoint lock")
+		}
+	}
+
 }
 
 func (b *Bus) ClientFactory() (*rpcclient.Client, error) {
-	return rpcclient.New(b.connCfg, nil)
+	b.clientsMu.RLock()
+	connCfg := *b.connCfg
+	b.clientsMu.RUnlock()
+
+	return rpcclient.New(&connCfg, nil)
+}
+
+// main returns the primary RPC client.
+func (b *Bus) main() *rpcclient.Client {
+	b.clientsMu.RLock()
+	defer b.clientsMu.RUnlock()
+
+	return b.mainClient
+}
+
+// secondary returns the secondary RPC client.
+func (b *Bus) secondary() *rpcclient.Client {
+	b.clientsMu.RLock()
+	defer b.clientsMu.RUnlock()
+
+	return b.secondaryClient
+}
+
+// janitor returns the RPC client reserved for cleanups.
+func (b *Bus) janitor() *rpcclient.Client {
+	b.clientsMu.RLock()
+	defer b.clientsMu.RUnlock()
+
+	return b.janitorClient
 }
 
 // Currency represents the currency type (btc) and the network params
@@ -299,7 +410,7 @@ type CreateWalletResult struct {
 // (true) or loaded (false). The value is meaningless if an error is returned.
 //
 // In case a new wallet is created, it'll be in loaded state by default.
-func loadOrCreateWallet(client *rpcclient.Client) (bool, error) {
+func loadOrCreateWallet(client *rpcclient.Client, walletName string) (bool, error) {
 	// Try to load wallet first.
 	_, err := client.LoadWallet(walletName)
 	if err == nil {
@@ -455,20 +566,24 @@ func blockFilterEnabled(client *rpcclient.Client, hash string) (bool, error) {
 	return true, nil
 }
 
-func (b *Bus) UnloadWallet() {
-	if err := b.janitorClient.UnloadWallet(nil); err != nil {
+// UnloadWallet unloads the wallet of the Bus from bitcoind, and shuts down
+// the janitor client.
+func (b *Bus) UnloadWallet() error {
+	if err := b.janitor().UnloadWallet(nil); err != nil {
 		log.WithFields(log.Fields{
-			"wallet": walletName,
+			"wallet": b.Wallet,
 			"error":  err,
 		}).Warn("Unable to unload wallet")
-		return
+		return err
 	}
 
 	log.WithFields(log.Fields{
-		"wallet": walletName,
+		"wallet": b.Wallet,
 	}).Info("Unloaded wallet successfully")
 
-	b.janitorClient.Shutdown()
+	b.janitor().Shutdown()
+
+	return nil
 }
 
 func (b *Bus) DumpLatestRescanTime() error {
@@ -478,17 +593,37 @@ func (b *Bus) DumpLatestRescanTime() error {
 	if err != nil {
 		log.WithFields(log.Fields{
 			"prefix": "worker",
-		}).Error("Error fetching blockheight: %s", err)
+		}).Errorf("Error fetching blockheight: %s", err)
 		return err
 
 	}
+
+	return b.writeCheckpoint(currentHeight)
+}
+
+// writeCheckpoint saves the rescan checkpoint of the wallet, which is in
+// sync up to the block at the given height.
+func (b *Bus) writeCheckpoint(height int64) error {
+	var scannedSince int64
+	if previous, err := b.Config.LoadRescanConf(b.Wallet); err == nil {
+		scannedSince = previous.ScannedSince
+	}
+
+	return b.saveCheckpoint(height, scannedSince)
+}
+
+// saveCheckpoint saves the rescan checkpoint of the wallet, which is in sync
+// up to the block at the given height, and was rescanned since the given
+// time after its descriptors were imported, if not zero.
+func (b *Bus) saveCheckpoint(height int64, scannedSince int64) error {
 	data := &config.ConfigurationRescan{
 		TimeStamp:       strconv.Itoa(int(time.Now().Unix())),
 		LastSyncTime:    time.Now().Format(time.ANSIC),
-		LastBlock:       currentHeight,
+		LastBlock:       height,
+		ScannedSince:    scannedSince,
 		SatstackVersion: version.Version,
 	}
-	err = config.WriteRescanConf(data)
+	err := b.Config.WriteRescanConf(b.Wallet, data)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"prefix": "worker",
@@ -0,0 +1,420 @@
+package bus
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	log "github.com/sirupsen/logrus"
+)
+
+// scanWaitInterval indicates how often the scan state is checked, while a job
+// waits for the scan in progress to complete.
+const scanWaitInterval = 5 * time.Second
+
+// maxFinishedJobs indicates the number of finished jobs that are kept in
+// memory, for querying through the control API.
+const maxFinishedJobs = 100
+
+// JobKind identifies the type of operation performed by a Job.
+type JobKind string
+
+const (
+	// JobImport is a JobKind for imports of account descriptors, which
+	// trigger a wallet rescan in bitcoind.
+	JobImport JobKind = "import"
+
+	// JobRescan is a JobKind for rescans of the wallet over a range of
+	// blocks.
+	JobRescan JobKind = "rescan"
+
+	// JobCirculationCheck is a JobKind for inflation checks against the
+	// connected full node ("running the numbers").
+	JobCirculationCheck JobKind = "circulation-check"
+)
+
+// JobState indicates the state of a Job.
+type JobState string
+
+const (
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// Job models a long-running operation performed in the background, such as
+// an import of descriptors or a rescan of the wallet.
+type Job struct {
+	ID        string     `json:"id"`
+	Kind      JobKind    `json:"kind"`
+	Accounts  []string   `json:"accounts,omitempty"` // IDs of the accounts concerned by the job, if any
+	State     JobState   `json:"state"`
+	Progress  *float64   `json:"progress,omitempty"` // percentage of the wallet rescan, while running
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+
+	// waiting indicates that the job is waiting for another scan to
+	// complete, and cancel is closed if it is cancelled meanwhile.
+	waiting bool
+	cancel  chan struct{}
+}
+
+// JobManager runs jobs and keeps track of their state.
+//
+// It is safe for concurrent use.
+type JobManager struct {
+	bus *Bus
+
+	mu   sync.Mutex
+	jobs []*Job
+}
+
+func newJobManager(b *Bus) *JobManager {
+	return &JobManager{bus: b}
+}
+
+// Start runs the given function as a job in a background goroutine, and
+// returns the ID of the job.
+func (m *JobManager) Start(kind JobKind, accounts []string, run func() error) string {
+	job := m.add(kind, accounts, false)
+
+	go m.run(job, run)
+
+	return job.ID
+}
+
+// StartScan runs the given function as a job in a background goroutine,
+// within a scan of the wallet in the given state (see Bus.Scan), and returns
+// the ID of the job.
+//
+// If another scan is in progress, such as the startup scan, the job waits
+// for it to complete, rather than failing. It stops waiting if the Bus is
+// closed, or if the job is cancelled.
+func (m *JobManager) StartScan(kind JobKind, accounts []string, state ScanState,
+	reason string, run func() error) string {
+	job := m.add(kind, accounts, true)
+
+	go m.run(job, func() error {
+		for {
+			if err := m.waitScan(job); err != nil {
+				return err
+			}
+
+			err := m.bus.Scan(state, reason, run)
+			if !errors.Is(err, ErrScanInProgress) {
+				return err
+			}
+
+			// Another scan started in the meantime.
+			m.mu.Lock()
+			job.waiting = true
+			m.mu.Unlock()
+		}
+	})
+
+	return job.ID
+}
+
+// waitScan blocks until no scan is in progress, and marks the job as no
+// longer waiting. It returns an error if the Bus was closed or the job was
+// cancelled meanwhile.
+func (m *JobManager) waitScan(job *Job) error {
+	ticker := time.NewTicker(scanWaitInterval)
+	defer ticker.Stop()
+
+	for m.bus.State.Pending() {
+		select {
+		case <-m.bus.done:
+			return ErrBusClosed
+		case <-job.cancel:
+			return ErrJobCancelled
+		case <-ticker.C:
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job.State == JobCancelled {
+		return ErrJobCancelled
+	}
+
+	job.waiting = false
+	m.resetAbort(job)
+
+	return nil
+}
+
+// Run runs the given function as a job, and blocks until it returns.
+//
+// If the job was cancelled, ErrJobCancelled is returned.
+func (m *JobManager) Run(kind JobKind, accounts []string, run func() error) error {
+	job := m.add(kind, accounts, false)
+
+	return m.run(job, run)
+}
+
+// List returns a snapshot of all the jobs, in the order they were started.
+func (m *JobManager) List() []Job {
+	m.mu.Lock()
+	jobs := make([]Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, *job)
+	}
+	m.mu.Unlock()
+
+	for idx := range jobs {
+		m.fillProgress(&jobs[idx])
+	}
+
+	return jobs
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (m *JobManager) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	job := m.find(id)
+	if job == nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+
+	result := *job
+	m.mu.Unlock()
+
+	m.fillProgress(&result)
+
+	return &result, nil
+}
+
+// Cancel cancels the running job with the given ID.
+//
+// Only jobs that scan the wallet can be cancelled, by aborting the ongoing
+// rescan in bitcoind. Since the rescan is aborted for the whole wallet, a job
+// cannot be cancelled while another one is scanning the wallet.
+//
+// The job is only marked as cancelled once the rescan was aborted. The lock
+// is held meanwhile, so that the job cannot finish in between.
+func (m *JobManager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.find(id)
+	if job == nil {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	}
+
+	if job.State != JobRunning {
+		return fmt.Errorf("%w: job is %s", ErrJobNotCancellable, job.State)
+	}
+
+	if !job.Kind.scans() {
+		return fmt.Errorf("%w: %s job", ErrJobNotCancellable, job.Kind)
+	}
+
+	// A job waiting for another scan is cancelled without aborting it.
+	if job.waiting {
+		close(job.cancel)
+		job.State = JobCancelled
+
+		return nil
+	}
+
+	for _, other := range m.jobs {
+		if other != job && other.State == JobRunning && other.Kind.scans() && !other.waiting {
+			return fmt.Errorf("%w: job %s is also scanning the wallet",
+				ErrJobNotCancellable, other.ID)
+		}
+	}
+
+	if err := m.bus.AbortRescan(); err != nil {
+		return err
+	}
+
+	job.State = JobCancelled
+
+	return nil
+}
+
+// Running checks whether a job of the given kind, concerning the account with
+// the given ID, is currently running.
+func (m *JobManager) Running(kind JobKind, accountID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, job := range m.jobs {
+		if job.Kind != kind || job.State != JobRunning {
+			continue
+		}
+
+		for _, id := range job.Accounts {
+			if id == accountID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// add registers a new running job. If waiting is true, the job waits for the
+// scan in progress before it starts, and can be cancelled meanwhile.
+func (m *JobManager) add(kind JobKind, accounts []string, waiting bool) *Job {
+	job := &Job{
+		ID:        newJobID(),
+		Kind:      kind,
+		Accounts:  accounts,
+		State:     JobRunning,
+		StartedAt: time.Now(),
+		waiting:   waiting,
+	}
+
+	if waiting {
+		job.cancel = make(chan struct{})
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !waiting {
+		m.resetAbort(job)
+	}
+
+	m.jobs = append(m.jobs, job)
+	m.prune()
+
+	log.WithFields(log.Fields{
+		"prefix": "jobs",
+		"id":     job.ID,
+		"kind":   job.Kind,
+	}).Info("Job started")
+
+	return job
+}
+
+func (m *JobManager) run(job *Job, run func() error) error {
+	err := run()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	job.EndedAt = &now
+
+	fields := log.Fields{
+		"prefix":   "jobs",
+		"id":       job.ID,
+		"kind":     job.Kind,
+		"duration": now.Sub(job.StartedAt).Round(time.Second),
+	}
+
+	switch {
+	case job.State == JobCancelled:
+		log.WithFields(fields).Info("Job cancelled")
+		err = ErrJobCancelled
+	case err != nil:
+		job.State = JobFailed
+		job.Error = err.Error()
+		log.WithFields(fields).WithField("error", err).Error("Job failed")
+	default:
+		job.State = JobSucceeded
+		log.WithFields(fields).Info("Job succeeded")
+	}
+
+	return err
+}
+
+// resetAbort clears the abort of chunked rescans requested for a previous
+// job, before the given job starts scanning the wallet. The abort is kept if
+// another job is scanning the wallet, so that cancelling it still stops its
+// rescan. The caller must hold the lock.
+//
+// The flag is only cleared here, under the lock also held by Cancel, so that
+// a job cancelled right as it starts cannot miss the abort.
+func (m *JobManager) resetAbort(job *Job) {
+	if !job.Kind.scans() {
+		return
+	}
+
+	for _, other := range m.jobs {
+		if other != job && other.State == JobRunning && other.Kind.scans() && !other.waiting {
+			return
+		}
+	}
+
+	m.bus.rescanAborted.Store(false)
+}
+
+// fillProgress populates the progress of a running job that scans the
+// wallet, from the scanning state reported by bitcoind.
+func (m *JobManager) fillProgress(job *Job) {
+	if job.State != JobRunning || !job.Kind.scans() {
+		return
+	}
+
+	walletInfo, err := m.bus.secondary().GetWalletInfo()
+	if err != nil {
+		return
+	}
+
+	if v, ok := walletInfo.Scanning.Value.(btcjson.ScanProgress); ok {
+		job.Progress = btcjson.Float64(v.Progress * 100)
+	}
+}
+
+// find returns the job with the given ID, or nil. The caller must hold the
+// lock.
+func (m *JobManager) find(id string) *Job {
+	for _, job := range m.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+
+	return nil
+}
+
+// prune drops the oldest finished jobs, so that at most maxFinishedJobs are
+// kept. The caller must hold the lock.
+func (m *JobManager) prune() {
+	finished := 0
+	for _, job := range m.jobs {
+		if job.State != JobRunning {
+			finished++
+		}
+	}
+
+	jobs := m.jobs[:0]
+	for _, job := range m.jobs {
+		if job.State != JobRunning && finished > maxFinishedJobs {
+			finished--
+			continue
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	m.jobs = jobs
+}
+
+// scans checks whether jobs of this kind trigger a wallet rescan in
+// bitcoind.
+func (k JobKind) scans() bool {
+	return k == JobImport || k == JobRescan
+}
+
+func newJobID() string {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		// Extremely unlikely; fall back to a time-based ID.
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(buf[:])
+}
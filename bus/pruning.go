@@ -0,0 +1,137 @@
+package bus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/rpcclient"
+	log "github.com/sirupsen/logrus"
+)
+
+// PruneMode indicates how SatStack handles imports and rescans that start
+// before the earliest block available on a pruned node.
+type PruneMode = string
+
+const (
+	// PruneRefuse is a PruneMode to refuse such imports and rescans with an
+	// error. This is the default.
+	PruneRefuse PruneMode = "refuse"
+
+	// PruneClamp is a PruneMode to start such imports and rescans from the
+	// earliest available block instead, and report a missing-history
+	// warning in the status endpoint.
+	PruneClamp PruneMode = "clamp"
+)
+
+// pruneHeight returns the height of the earliest block available on the
+// node, or 0 if the node is not pruned.
+//
+// The prune height increases as the node prunes old blocks, so it is queried
+// every time rather than at startup.
+func pruneHeight(client *rpcclient.Client) (int64, error) {
+	info, err := client.GetBlockChainInfo()
+	if err != nil {
+		return 0, err
+	}
+
+	if !info.Pruned {
+		return 0, nil
+	}
+
+	return int64(info.PruneHeight), nil
+}
+
+// checkPrunedStart checks whether a scan starting at the given height can be
+// performed on the node, and returns the height it should actually start
+// from, according to the PruneMode of the Bus.
+//
+// The subject is used to identify the operation in errors and warnings, for
+// example an account descriptor.
+func (b *Bus) checkPrunedStart(client *rpcclient.Client, height int64, subject string) (int64, error) {
+	if !b.Pruned {
+		return height, nil
+	}
+
+	earliest, err := pruneHeight(client)
+	if err != nil {
+		return -1, err
+	}
+
+	if height >= earliest {
+		return height, nil
+	}
+
+	msg := fmt.Sprintf("%s: blocks before height %d are pruned, but scan starts at height %d",
+		subject, earliest, height)
+
+	if b.PruneMode != PruneClamp {
+		return -1, fmt.Errorf("%w: %s (set \"prunemode\": \"%s\" in lss.json to scan "+
+			"from the earliest available block instead)", ErrPrunedHistory, msg, PruneClamp)
+	}
+
+	warning := msg + "; transactions before it may be missing"
+	b.addWarning(warning)
+
+	log.WithFields(log.Fields{
+		"prefix": "worker",
+		"height": earliest,
+	}).Warn(warning)
+
+	return earliest, nil
+}
+
+// checkPrunedAge is similar to checkPrunedStart, but operates on an import
+// timestamp rather than a height.
+func (b *Bus) checkPrunedAge(client *rpcclient.Client, age uint32, subject string) (uint32, error) {
+	if !b.Pruned {
+		return age, nil
+	}
+
+	height, err := heightAtTime(client, time.Unix(int64(age), 0))
+	if err != nil {
+		return 0, err
+	}
+
+	start, err := b.checkPrunedStart(client, height, subject)
+	if err != nil {
+		return 0, err
+	}
+
+	if start == height {
+		return age, nil
+	}
+
+	hash, err := client.GetBlockHash(start)
+	if err != nil {
+		return 0, err
+	}
+
+	header, err := client.GetBlockHeaderVerbose(hash)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(header.Time), nil
+}
+
+// Warnings returns the warnings recorded by the Bus, such as missing
+// history due to pruning.
+func (b *Bus) Warnings() []string {
+	b.warningsMu.Lock()
+	defer b.warningsMu.Unlock()
+
+	return append([]string(nil), b.warnings...)
+}
+
+func (b *Bus) addWarning(warning string) {
+	b.warningsMu.Lock()
+	defer b.warningsMu.Unlock()
+
+	for _, w := range b.warnings {
+		if w == warning {
+			return
+		}
+	}
+
+	b.warnings = append(b.warnings, warning)
+}
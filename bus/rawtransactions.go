@@ -31,7 +31,7 @@ func (b *Bus) SendTransaction(tx string) (*chainhash.Hash, error) {
 		return nil, err
 	}
 
-	chainHash, err := b.mainClient.SendRawTransaction(&msgTx, true)
+	chainHash, err := b.main().SendRawTransaction(&msgTx, true)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"hex":   tx,
@@ -0,0 +1,193 @@
+package bus
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ScanState indicates the state of SatStack with regards to the operations
+// that must be completed before explorer requests can be served correctly,
+// such as importing descriptors and rescanning the wallet.
+type ScanState string
+
+const (
+	// StateInitializing is the initial ScanState, while the Bus is warming up.
+	StateInitializing ScanState = "initializing"
+
+	// StateWaitingIBD indicates that the worker is waiting for the Bitcoin
+	// node to complete its Initial Block Download.
+	StateWaitingIBD ScanState = "waiting-ibd"
+
+	// StateRunningNumbers indicates that the worker is performing inflation
+	// checks against the Bitcoin node.
+	StateRunningNumbers ScanState = "running-numbers"
+
+	// StateImporting indicates that account descriptors are being imported
+	// in the wallet, which triggers a rescan in bitcoind.
+	StateImporting ScanState = "importing"
+
+	// StateRescanning indicates that the wallet is being rescanned over a
+	// range of blocks.
+	StateRescanning ScanState = "rescanning"
+
+	// StateReady indicates that all pending operations have completed, and
+	// explorer requests can be served.
+	StateReady ScanState = "ready"
+
+	// StateFailed indicates that one of the startup operations failed.
+	StateFailed ScanState = "failed"
+
+	// StateIncomplete indicates that the startup import or rescan was
+	// cancelled, so the wallet may be missing transactions until the scan
+	// is resumed on next start.
+	StateIncomplete ScanState = "incomplete"
+)
+
+// maxScanHistory indicates the number of most recent transitions kept in the
+// history of the ScanStateMachine.
+const maxScanHistory = 50
+
+// scanTransitions lists the valid transitions from every ScanState.
+var scanTransitions = map[ScanState][]ScanState{
+	StateInitializing:   {StateWaitingIBD, StateFailed},
+	StateWaitingIBD:     {StateRunningNumbers, StateImporting, StateRescanning, StateReady, StateFailed},
+	StateRunningNumbers: {StateImporting, StateRescanning, StateReady, StateFailed},
+	StateImporting:      {StateReady, StateFailed, StateIncomplete},
+	StateRescanning:     {StateReady, StateFailed, StateIncomplete},
+	StateReady:          {StateImporting, StateRescanning},
+	StateFailed:         {StateImporting, StateRescanning},
+	StateIncomplete:     {StateImporting, StateRescanning},
+}
+
+// ScanTransition records a change of ScanState.
+type ScanTransition struct {
+	From   ScanState `json:"from"`
+	To     ScanState `json:"to"`
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// ScanStateMachine guards the ScanState of the Bus, and keeps a history of
+// its transitions.
+//
+// It is safe for concurrent use.
+type ScanStateMachine struct {
+	mu      sync.RWMutex
+	state   ScanState
+	history []ScanTransition
+}
+
+func newScanStateMachine() *ScanStateMachine {
+	return &ScanStateMachine{state: StateInitializing}
+}
+
+// Current returns the current ScanState.
+func (m *ScanStateMachine) Current() ScanState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.state
+}
+
+// Pending checks whether SatStack is still performing operations that must
+// complete before explorer requests can be served correctly.
+func (m *ScanStateMachine) Pending() bool {
+	state := m.Current()
+	return state != StateReady && state != StateFailed && state != StateIncomplete
+}
+
+// History returns a copy of the most recent transitions.
+func (m *ScanStateMachine) History() []ScanTransition {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]ScanTransition(nil), m.history...)
+}
+
+// Transition changes the current ScanState to the given one, and returns the
+// previous state. An error is returned if the transition is not valid from
+// the current state, in which case the state is left unchanged.
+func (m *ScanStateMachine) Transition(to ScanState, reason string) (ScanState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from := m.state
+	if !canTransition(from, to) {
+		return from, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+
+	m.state = to
+	m.history = append(m.history, ScanTransition{
+		From:   from,
+		To:     to,
+		At:     time.Now(),
+		Reason: reason,
+	})
+
+	if len(m.history) > maxScanHistory {
+		m.history = m.history[len(m.history)-maxScanHistory:]
+	}
+
+	log.WithFields(log.Fields{
+		"prefix": "state",
+		"from":   from,
+		"to":     to,
+		"reason": reason,
+	}).Debug("Scan state changed")
+
+	return from, nil
+}
+
+// Scan runs an operation that scans the wallet at runtime, such as an import
+// or a rescan started through the control API, while in the given state.
+//
+// The operation is refused with ErrScanInProgress if another one is running.
+// Once it returns, the state goes back to StateReady, unless the operation
+// failed and SatStack was not ready beforehand. If the startup scan was
+// cancelled, the state goes back to StateIncomplete, since the operation
+// does not resume it.
+func (b *Bus) Scan(state ScanState, reason string, run func() error) error {
+	from, err := b.State.Transition(state, reason)
+	if err != nil {
+		return fmt.Errorf("%w (%s)", ErrScanInProgress, from)
+	}
+
+	err = run()
+
+	switch {
+	case from == StateIncomplete:
+		b.mustTransition(StateIncomplete, reason)
+	case err == nil, from == StateReady:
+		b.mustTransition(StateReady, reason)
+	default:
+		b.mustTransition(StateFailed, err.Error())
+	}
+
+	return err
+}
+
+// mustTransition performs a transition that is expected to be valid, and
+// logs an error otherwise. Unlike Bus.Scan, it does not stop the caller from
+// proceeding after an invalid transition, in which case the state is left
+// unchanged: it must only be used where the current state is known.
+func (b *Bus) mustTransition(to ScanState, reason string) {
+	if _, err := b.State.Transition(to, reason); err != nil {
+		log.WithFields(log.Fields{
+			"prefix": "state",
+			"error":  err,
+		}).Error("Unexpected scan state transition")
+	}
+}
+
+func canTransition(from ScanState, to ScanState) bool {
+	for _, state := range scanTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+
+	return false
+}
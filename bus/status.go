@@ -26,12 +26,19 @@ const (
 	// of descriptors. This is typically the case when LSS is launched, while it
 	// is "running the numbers".
 	//
-	// Use this Status when Bus.IsPendingScan is set to true.
+	// Use this Status when Bus.State is pending.
 	PendingScan Status = "pending-scan"
 
 	// Scanning is a Status to indicate that the Bitcoin Core node is currently
 	// importing account descriptors into its wallet.
 	Scanning Status = "scanning"
+
+	// Incomplete is a Status to indicate that the startup scan of the wallet
+	// was cancelled, so explorer responses may be missing transactions
+	// until LSS is restarted.
+	//
+	// Use this Status when Bus.State is StateIncomplete.
+	Incomplete Status = "incomplete"
 )
 
 // ExplorerStatus represents the structure of payload returned by GetStatus
@@ -41,9 +48,19 @@ type ExplorerStatus struct {
 	TxIndex      bool     `json:"txindex"`
 	BlockFilter  bool     `json:"block_filter"`
 	Pruned       bool     `json:"pruned"`
+	PruneHeight  *int64   `json:"prune_height,omitempty"`
 	Chain        string   `json:"chain"`
 	Currency     Currency `json:"currency"`
 	Status       Status   `json:"status"`
 	SyncProgress *float64 `json:"sync_progress,omitempty"`
 	ScanProgress *float64 `json:"scan_progress,omitempty"`
+
+	// ScanState is the state of the startup and scan operations of LSS, and
+	// ScanHistory the transitions that led to it.
+	ScanState   ScanState        `json:"scan_state"`
+	ScanHistory []ScanTransition `json:"scan_history,omitempty"`
+
+	// Warnings lists issues that may cause missing transactions, such as
+	// scans clamped to the earliest block available on a pruned node.
+	Warnings []string `json:"warnings,omitempty"`
 }
@@ -11,7 +11,7 @@ import (
 const fallbackFee = btcutil.Amount(1)
 
 func (b *Bus) EstimateSmartFee(target int64, mode string) btcutil.Amount {
-	fee, err := b.mainClient.EstimateSmartFee(target, getMode(mode))
+	fee, err := b.main().EstimateSmartFee(target, getMode(mode))
 
 	// If failed to get smart fee estimate, fallback to fallbackFee.
 	// Example: if the full-node is a regtest chain, there are normally
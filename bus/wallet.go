@@ -2,12 +2,15 @@ package bus
 
 import (
 	"encoding/json"
+	"math"
 	"time"
 
 	"fmt"
 
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/rpcclient"
 
+	"github.com/ledgerhq/satstack/config"
 	"github.com/ledgerhq/satstack/protocol"
 	"github.com/ledgerhq/satstack/types"
 
@@ -31,7 +34,7 @@ func (b *Bus) ListTransactions(blockHash *string) ([]btcjson.ListTransactionsRes
 		}
 	}
 
-	txs, err := b.mainClient.ListSinceBlockMinConfWatchOnly(blockHashNative, 1, true)
+	txs, err := b.main().ListSinceBlockMinConfWatchOnly(blockHashNative, 1, true)
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +43,7 @@ func (b *Bus) ListTransactions(blockHash *string) ([]btcjson.ListTransactionsRes
 }
 
 func (b *Bus) GetTransactionHex(hash *chainhash.Hash) (string, error) {
-	tx, err := b.mainClient.GetTransactionWatchOnly(hash, true)
+	tx, err := b.main().GetTransactionWatchOnly(hash, true)
 	if err != nil {
 		return "", err
 	}
@@ -48,6 +51,54 @@ func (b *Bus) GetTransactionHex(hash *chainhash.Hash) (string, error) {
 	return tx.Hex, nil
 }
 
+// GetTransactionBlockHash returns the hash of the block containing the given
+// wallet transaction, or nil if the transaction is unconfirmed.
+func (b *Bus) GetTransactionBlockHash(hash *chainhash.Hash) (*chainhash.Hash, error) {
+	tx, err := b.main().GetTransactionWatchOnly(hash, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.BlockHash == "" {
+		return nil, nil
+	}
+
+	return utils.ParseChainHash(tx.BlockHash)
+}
+
+// GetWalletFee returns the fee paid by the given wallet transaction, as
+// reported by bitcoind, or nil if unknown. bitcoind only knows the fee of
+// transactions where all inputs belong to the wallet.
+func (b *Bus) GetWalletFee(hash *chainhash.Hash) (*btcutil.Amount, error) {
+	tx, err := b.main().GetTransactionWatchOnly(hash, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if tx.Fee == 0 {
+		return nil, nil
+	}
+
+	// Fees are reported as negative amounts on outgoing transactions.
+	fee, err := btcutil.NewAmount(math.Abs(tx.Fee))
+	if err != nil {
+		return nil, err
+	}
+
+	return &fee, nil
+}
+
+// HasAddress returns whether the given address belongs to one of the
+// descriptors imported in the wallet of the Bus.
+func (b *Bus) HasAddress(address string) (bool, error) {
+	info, err := b.main().GetAddressInfo(address)
+	if err != nil {
+		return false, err
+	}
+
+	return info.IsMine || info.IsWatchOnly, nil
+}
+
 type RescanResult struct {
 	StartHeight uint32 `json:"start_height"`
 	StopHeight  uint32 `json:"stop_height"`
@@ -159,7 +210,7 @@ func (b *Bus) GetTransaction(hash string) (*types.Transaction, error) {
 
 	switch b.TxIndex {
 	case true:
-		txRaw, err := b.mainClient.GetRawTransaction(chainHash)
+		txRaw, err := b.main().GetRawTransaction(chainHash)
 		if err != nil {
 			return nil, err
 		}
@@ -167,7 +218,7 @@ func (b *Bus) GetTransaction(hash string) (*types.Transaction, error) {
 		tx = protocol.DecodeMsgTx(txRaw.MsgTx(), b.Params)
 
 	case false:
-		txRaw, err := b.mainClient.GetTransactionWatchOnly(chainHash, true)
+		txRaw, err := b.main().GetTransactionWatchOnly(chainHash, true)
 		if err != nil {
 			return nil, err
 		}
@@ -185,20 +236,22 @@ func (b *Bus) GetTransaction(hash string) (*types.Transaction, error) {
 	return tx, nil
 }
 
-func (b *Bus) checkWalletSyncStatus() error {
+// walletScanning checks whether bitcoind is currently scanning the wallet,
+// for example due to a rescan started before SatStack was restarted.
+func (b *Bus) walletScanning() (bool, error) {
 
 	client, err := b.ClientFactory()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	defer client.Shutdown()
 
-	log.Debug("checkWalletSyncStatus")
+	log.Debug("walletScanning")
 
 	walletInfo, err := client.GetWalletInfo()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	switch v := walletInfo.Scanning.Value.(type) {
@@ -208,19 +261,27 @@ func (b *Bus) checkWalletSyncStatus() error {
 			"duration": utils.HumanizeDuration(
 				time.Duration(v.Duration) * time.Second),
 		}).Debug("satsstack wallet is syncing")
-		b.IsPendingScan = true
+		return true, nil
 	default:
 		// Not scanning currently, or scan is complete.
 		log.Debug("wallet is not syncing")
-		b.IsPendingScan = false
+		return false, nil
 	}
-
-	return nil
 }
 
+// rescanChunkSize is the number of blocks rescanned at once, between two
+// rescan checkpoints. This is about two weeks of blocks.
+const rescanChunkSize = 2016
+
 // Triggers the bitcoind api to rescan the wallet, in case the wallet
 // satstack already existed
-func (b *Bus) rescanWallet(startHeight int64, endHeight int64) error {
+//
+// The blocks are rescanned in chunks of rescanChunkSize. If checkpoint is
+// true, the rescan checkpoint is saved after every chunk, so that an
+// interrupted rescan resumes from the last completed chunk on next start.
+// The checkpoint never moves backwards, such as when rescanning blocks that
+// were already scanned.
+func (b *Bus) rescanWallet(startHeight int64, endHeight int64, checkpoint bool) error {
 
 	client, err := b.ClientFactory()
 	if err != nil {
@@ -229,12 +290,64 @@ func (b *Bus) rescanWallet(startHeight int64, endHeight int64) error {
 
 	defer client.Shutdown()
 
+	startHeight, err = b.checkPrunedStart(client, startHeight, "wallet rescan")
+	if err != nil {
+		return err
+	}
+
 	log.WithFields(log.Fields{
 		"prefix": "RescanWallet",
 	}).Infof("Rescanning Wallet start_height: %d, end_height %d", startHeight, endHeight)
 
-	b.IsPendingScan = true
+	lastBlock := int64(-1)
+	if checkpoint {
+		if previous, err := b.getPreviousRescanBlock(); err == nil {
+			lastBlock = previous
+		}
+	}
+
+	for from := startHeight; from <= endHeight; from += rescanChunkSize {
+		// bitcoind cannot abort a rescan between two chunks, so stop here
+		// instead.
+		select {
+		case <-b.done:
+			return ErrRescanAborted
+		default:
+		}
+
+		if b.rescanAborted.Load() {
+			return ErrRescanAborted
+		}
 
+		to := from + rescanChunkSize - 1
+		if to > endHeight {
+			to = endHeight
+		}
+
+		if err := rescanBlockchain(client, from, to); err != nil {
+			return err
+		}
+
+		// Failures are logged by writeCheckpoint, and only cost the
+		// progress of the chunk if the rescan is interrupted.
+		if checkpoint && to > lastBlock {
+			if err := b.writeCheckpoint(to); err == nil {
+				lastBlock = to
+			}
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"prefix": "RescanWallet",
+	}).Infof("Rescan wallet was successful:  start_height: %d, stop_height: %d", startHeight, endHeight)
+
+	return nil
+
+}
+
+// rescanBlockchain rescans the blocks between the given heights, both
+// inclusive. This is a blocking operation.
+func rescanBlockchain(client *rpcclient.Client, startHeight int64, endHeight int64) error {
 	var params []json.RawMessage
 	var rescanResult RescanResult
 
@@ -278,16 +391,12 @@ func (b *Bus) rescanWallet(startHeight int64, endHeight int64) error {
 
 	log.WithFields(log.Fields{
 		"prefix": "RescanWallet",
-	}).Infof("Rescan wallet was successful:  start_height: %d, stop_height: %d", rescanResult.StartHeight, rescanResult.StopHeight)
-
-	b.IsPendingScan = false
+	}).Debugf("Rescanned blocks:  start_height: %d, stop_height: %d", rescanResult.StartHeight, rescanResult.StopHeight)
 
 	return nil
-
 }
 
 func (b *Bus) AbortRescan() error {
-
 	var params []json.RawMessage
 	var abortRescan bool
 
@@ -298,6 +407,9 @@ func (b *Bus) AbortRescan() error {
 
 	defer client.Shutdown()
 
+	// Also stop chunked rescans, in case no chunk is being rescanned.
+	b.rescanAborted.Store(true)
+
 	result, err := client.RawRequest("abortrescan", params)
 
 	if err != nil {
@@ -306,6 +418,9 @@ func (b *Bus) AbortRescan() error {
 			"error":  err,
 		}).Error("Failed to abort wallet rescan")
 
+		// The rescan goes on, so its next chunks must not be skipped.
+		b.rescanAborted.Store(false)
+
 		return err
 	}
 
@@ -320,8 +435,61 @@ func (b *Bus) AbortRescan() error {
 		"prefix": "AbortRescan",
 	}).Infof("Abort rescan successful: %t", abortRescan)
 
-	b.IsPendingScan = false
-
 	return nil
 
 }
+
+// RescanRange rescans the wallet between the given heights, both inclusive.
+// This is a blocking operation.
+//
+// The progress of the rescan is checkpointed only if the wallet was in sync
+// up to the start of the range, so that the checkpoint never skips blocks,
+// and only past the previous checkpoint, so that it never moves backwards.
+func (b *Bus) RescanRange(startHeight int64, endHeight int64) error {
+	previous, err := b.getPreviousRescanBlock()
+	checkpoint := err == nil && previous >= 0 && startHeight <= previous+1
+
+	return b.rescanWallet(startHeight, endHeight, checkpoint)
+}
+
+// RescanAccount rescans the blockchain for the transactions of a single
+// account, starting from the block at the given height up to the tip.
+//
+// bitcoind cannot rescan the wallet for a subset of descriptors, so this is
+// done by importing the descriptors of the account again, with a timestamp
+// matching the given height. This is a blocking operation.
+func (b *Bus) RescanAccount(account config.Account, startHeight int64) error {
+	client, err := b.ClientFactory()
+	if err != nil {
+		return err
+	}
+
+	defer client.Shutdown()
+
+	startHeight, err = b.checkPrunedStart(client, startHeight,
+		fmt.Sprintf("account %s", account.ID()))
+	if err != nil {
+		return err
+	}
+
+	hash, err := client.GetBlockHash(startHeight)
+	if err != nil {
+		return err
+	}
+
+	header, err := client.GetBlockHeaderVerbose(hash)
+	if err != nil {
+		return err
+	}
+
+	accountDescriptors, err := descriptors(client, account)
+	if err != nil {
+		return err
+	}
+
+	for idx := range accountDescriptors {
+		accountDescriptors[idx].Age = uint32(header.Time)
+	}
+
+	return ImportDescriptors(client, accountDescriptors)
+}
@@ -1,6 +1,7 @@
 package bus
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -18,7 +19,7 @@ import (
 
 func waitForIBD(b *Bus) error {
 	for {
-		info, err := b.mainClient.GetBlockChainInfo()
+		info, err := b.main().GetBlockChainInfo()
 		if err != nil {
 			return err
 		}
@@ -46,7 +47,7 @@ func waitForIBD(b *Bus) error {
 }
 
 func getImportProgress(b *Bus) error {
-	walletInfo, err := b.secondaryClient.GetWalletInfo()
+	walletInfo, err := b.secondary().GetWalletInfo()
 	if err != nil {
 		return err
 	}
@@ -70,6 +71,19 @@ func getImportProgress(b *Bus) error {
 // ImportAccounts will import the descriptors corresponding to the accounts
 // into the Bitcoin Core wallet. This is a blocking operation.
 func (b *Bus) ImportAccounts(accounts []config.Account) error {
+	return b.importAccounts(accounts, false)
+}
+
+// ReimportAccounts is similar to ImportAccounts, but also imports the
+// descriptors already in the wallet, which rescans the wallet from the
+// birthday of the accounts. This is a blocking operation.
+func (b *Bus) ReimportAccounts(accounts []config.Account) error {
+	return b.importAccounts(accounts, true)
+}
+
+// importAccounts imports the descriptors of the accounts. Descriptors that are
+// already in the wallet are skipped, unless force is true.
+func (b *Bus) importAccounts(accounts []config.Account, force bool) error {
 	// Skip import of descriptors, if no account config found. SatStack
 	// will run in zero-configuration mode.
 	if accounts == nil {
@@ -83,47 +97,194 @@ func (b *Bus) ImportAccounts(accounts []config.Account) error {
 
 	defer client.Shutdown()
 
-	var allDescriptors []descriptor
-	for _, account := range accounts {
-		accountDescriptors, err := descriptors(client, account)
+	descriptorsToImport, err := b.descriptorsToImport(client, accounts, force)
+	if err != nil {
+		return err
+	}
+
+	if len(descriptorsToImport) == 0 {
+		log.WithField(
+			"prefix", "worker",
+		).Info("No (new) descriptors to import")
+		return nil
+	}
+
+	return ImportDescriptors(client, descriptorsToImport)
+}
+
+// importNewWallet imports the descriptors of the accounts into a wallet
+// without rescan checkpoint, such as a new wallet, and rescans the wallet
+// from the birthday of the earliest account up to the tip. This is a
+// blocking operation.
+//
+// Unlike ImportAccounts, where bitcoind rescans the chain within a single
+// call that is lost if interrupted, the descriptors are imported with the
+// current time as timestamp, and the wallet is then rescanned in chunks,
+// checkpointed as they complete.
+func (b *Bus) importNewWallet(accounts []config.Account) error {
+	if accounts == nil {
+		return nil
+	}
+
+	client, err := b.ClientFactory()
+	if err != nil {
+		return err
+	}
+
+	defer client.Shutdown()
+
+	descriptorsToImport, err := b.descriptorsToImport(client, accounts, false)
+	if err != nil {
+		return err
+	}
+
+	if len(descriptorsToImport) == 0 {
+		log.WithField(
+			"prefix", "worker",
+		).Info("No (new) descriptors to import")
+		return nil
+	}
+
+	since := descriptorsToImport[0].Age
+	for _, desc := range descriptorsToImport {
+		if desc.Age < since {
+			since = desc.Age
+		}
+	}
+
+	// Like bitcoind, start from blocks slightly older than the timestamp, to
+	// account for inaccurate block times.
+	startHeight, err := heightAtTime(client, time.Unix(int64(since), 0).Add(-importTimestampMargin))
+	if err != nil {
+		return err
+	}
+
+	// The timestamp was already checked against the pruned blocks, but not
+	// the margin.
+	if b.Pruned {
+		earliest, err := pruneHeight(client)
 		if err != nil {
-			return err // return bare error, since it already has a ctx
+			return err
+		}
+
+		if startHeight < earliest {
+			startHeight = earliest
 		}
+	}
+
+	endHeight, err := client.GetBlockCount()
+	if err != nil {
+		return err
+	}
 
-		allDescriptors = append(allDescriptors, accountDescriptors...)
+	now := uint32(time.Now().Unix())
+	for idx := range descriptorsToImport {
+		descriptorsToImport[idx].Age = now
+	}
+
+	if err := ImportDescriptors(client, descriptorsToImport); err != nil {
+		return err
+	}
+
+	// The blocks before the earliest birthday hold no transaction of the
+	// accounts, so the wallet is in sync up to there. This also replaces
+	// any checkpoint left by a previous wallet.
+	//
+	// A checkpoint at -1 would mean that there is none, and make the next
+	// start skip the rescan, since the descriptors are already imported.
+	// The genesis block has no spendable output, so the checkpoint can
+	// start there.
+	lastBlock := startHeight - 1
+	if lastBlock < 0 {
+		lastBlock = 0
+	}
+
+	if err := b.saveCheckpoint(lastBlock, int64(since)); err != nil {
+		return err
 	}
 
+	return b.rescanWallet(startHeight, endHeight, true)
+}
+
+// descriptorsToImport returns the descriptors of the accounts to import,
+// with the timestamp from which they must be scanned. Descriptors that are
+// already in the wallet are skipped, unless force is true.
+func (b *Bus) descriptorsToImport(client *rpcclient.Client, accounts []config.Account,
+	force bool) ([]descriptor, error) {
 	var descriptorsToImport []descriptor
-	for _, descriptor := range allDescriptors {
-		address, err := DeriveAddress(client, descriptor.Value, descriptor.Depth)
-		if err != nil {
-			return fmt.Errorf("%s (%s - #%d): %w",
-				ErrDeriveAddress, descriptor.Value, descriptor.Depth, err)
+	for _, account := range accounts {
+		if account.Removed {
+			continue
 		}
 
-		addressInfo, err := client.GetAddressInfo(*address)
+		accountDescriptors, err := descriptors(client, account)
 		if err != nil {
-			return fmt.Errorf("%s (%s): %w", ErrAddressInfo, *address, err)
+			return nil, err // return bare error, since it already has a ctx
 		}
 
-		if !addressInfo.IsWatchOnly {
-			descriptorsToImport = append(descriptorsToImport, descriptor)
+		var accountDescriptorsToImport []descriptor
+		for _, descriptor := range accountDescriptors {
+			if force {
+				accountDescriptorsToImport = append(accountDescriptorsToImport, descriptor)
+				continue
+			}
+
+			address, err := DeriveAddress(client, descriptor.Value, descriptor.Depth)
+			if err != nil {
+				return nil, fmt.Errorf("%s (%s - #%d): %w",
+					ErrDeriveAddress, descriptor.Value, descriptor.Depth, err)
+			}
+
+			addressInfo, err := client.GetAddressInfo(*address)
+			if err != nil {
+				return nil, fmt.Errorf("%s (%s): %w", ErrAddressInfo, *address, err)
+			}
+
+			if !addressInfo.IsWatchOnly {
+				accountDescriptorsToImport = append(accountDescriptorsToImport, descriptor)
+			}
 		}
-	}
 
-	if len(descriptorsToImport) == 0 {
-		log.WithField(
-			"prefix", "worker",
-		).Info("No (new) descriptors to import")
-		return nil
+		// On a pruned node, the rescan triggered by the import cannot start
+		// before the earliest available block.
+		for idx := range accountDescriptorsToImport {
+			age, err := b.checkPrunedAge(client, accountDescriptorsToImport[idx].Age,
+				fmt.Sprintf("account %s", account.ID()))
+			if err != nil {
+				return nil, err
+			}
+
+			accountDescriptorsToImport[idx].Age = age
+		}
+
+		// If the account birthday is unknown, use the compact block filters
+		// to skip the part of the chain where the account has no activity.
+		if account.Birthday == nil && b.BlockFilter && len(accountDescriptorsToImport) > 0 {
+			age, err := b.prescanAge(client, accountDescriptorsToImport,
+				accountDescriptorsToImport[0].Age)
+			if err != nil {
+				return nil, err
+			}
+
+			log.WithFields(log.Fields{
+				"prefix":    "worker",
+				"timestamp": time.Unix(int64(age), 0).UTC().Format(time.RFC3339),
+			}).Info("Found earliest account activity using compact block filters")
+
+			for idx := range accountDescriptorsToImport {
+				accountDescriptorsToImport[idx].Age = age
+			}
+		}
+
+		descriptorsToImport = append(descriptorsToImport, accountDescriptorsToImport...)
 	}
 
-	return ImportDescriptors(client, descriptorsToImport)
+	return descriptorsToImport, nil
 }
 
-func getPreviousRescanBlock() (int64, error) {
+func (b *Bus) getPreviousRescanBlock() (int64, error) {
 
-	configRescan, err := config.LoadRescanConf()
+	configRescan, err := b.Config.LoadRescanConf(b.Wallet)
 
 	if err != nil {
 		return -1, err
@@ -137,13 +298,7 @@ func getPreviousRescanBlock() (int64, error) {
 func descriptors(client *rpcclient.Client, account config.Account) ([]descriptor, error) {
 	var ret []descriptor
 
-	var depth int
-	switch account.Depth {
-	case nil:
-		depth = defaultAccountDepth
-	default:
-		depth = *account.Depth
-	}
+	depth := AccountDepth(account)
 
 	var age uint32
 	switch account.Birthday {
@@ -181,7 +336,7 @@ func descriptors(client *rpcclient.Client, account config.Account) ([]descriptor
 func runTheNumbers(b *Bus) error {
 	log.WithField("prefix", "worker").Info("Computing circulating supply...")
 
-	info, err := b.mainClient.GetTxOutSetInfo()
+	info, err := b.main().GetTxOutSetInfo()
 	if err != nil {
 		return err
 	}
@@ -216,7 +371,7 @@ func runTheNumbers(b *Bus) error {
 	return nil
 }
 
-func (b *Bus) Worker(config *config.Configuration, circulationCheck bool,
+func (b *Bus) Worker(config *config.WalletConfig, circulationCheck bool,
 	forceImportDesc bool) {
 	importDone := make(chan bool)
 
@@ -241,36 +396,44 @@ func (b *Bus) Worker(config *config.Configuration, circulationCheck bool,
 		}
 	}
 
+	// fail records the failure of a startup operation, and shuts down
+	// SatStack.
+	fail := func(reason string) {
+		b.mustTransition(StateFailed, reason)
+		sendInterruptSignal()
+	}
+
 	go func() {
+		b.mustTransition(StateWaitingIBD, "worker started")
+
 		if err := waitForIBD(b); err != nil {
 			log.WithFields(log.Fields{
 				"prefix": "worker",
 				"error":  err,
 			}).Error("Failed during Initial Block Download")
 
-			sendInterruptSignal()
+			fail("initial block download")
 			return
 		}
 
 		if circulationCheck {
-			b.IsPendingScan = true
+			b.mustTransition(StateRunningNumbers, "circulation check requested")
 
-			if err := runTheNumbers(b); err != nil {
+			if err := b.Jobs.Run(JobCirculationCheck, nil, func() error {
+				return runTheNumbers(b)
+			}); err != nil {
 				log.WithFields(log.Fields{
 					"prefix": "worker",
 					"error":  err,
 				}).Error("Failed while running the numbers")
 
-				sendInterruptSignal()
+				fail("circulation check")
 				return
 			}
-
-			b.IsPendingScan = false
-
 		}
 
 		// We check whether the lss_rescan.json exists
-		startHeight, err := getPreviousRescanBlock()
+		startHeight, err := b.getPreviousRescanBlock()
 		if err != nil {
 			log.Debugf("No lss_rescan.json was found: %s", err)
 		}
@@ -278,13 +441,13 @@ func (b *Bus) Worker(config *config.Configuration, circulationCheck bool,
 		// We allow the user to force an import of all descriptors
 		// which will trigger a rescan automatically using the timestamp
 		// in the importDescriptorRequest
-		if forceImportDesc || isNewWallet || startHeight == -1 {
+		if forceImportDesc || b.isNewWallet || startHeight == -1 {
 
 			// Check whether the wallet is syncing in the background
 			// if so, the sync is aborted so that we can import the
 			// descriptors in the next step
 			if forceImportDesc {
-				err := b.checkWalletSyncStatus()
+				scanning, err := b.walletScanning()
 
 				if err != nil {
 					log.WithFields(log.Fields{
@@ -292,51 +455,78 @@ func (b *Bus) Worker(config *config.Configuration, circulationCheck bool,
 						"error":  err,
 					}).Error("failed to check wallet status")
 
-					sendInterruptSignal()
+					fail("wallet status")
 					return
 
 				}
 
-				if b.IsPendingScan {
+				if scanning {
 					// Interrupt Scan
 					err = b.AbortRescan()
 					if err != nil {
-						sendInterruptSignal()
+						fail("abort rescan")
 						return
 					}
 				}
 			}
 
-			// The ImportDescriptor call is a blocking operation
-			// and will automatically trigger a wallet scan
-			b.IsPendingScan = true
+			// The import is a blocking operation, followed by a
+			// rescan of the wallet from the earliest birthday, unless
+			// the descriptors are imported again
+			b.mustTransition(StateImporting, "importing account descriptors")
 
-			if err := b.ImportAccounts(config.Accounts); err != nil {
+			// Discover the birthday of accounts without one, so that the
+			// import doesn't rescan the whole chain since 2013.
+			if b.BlockFilter {
+				if err := b.DiscoverBirthdays(config); err != nil {
+					log.WithFields(log.Fields{
+						"prefix": "worker",
+						"error":  err,
+					}).Warn("Failed to discover account birthdays")
+				}
+			}
+
+			accounts := config.ActiveAccounts()
+			err := b.Jobs.Run(JobImport, accountIDs(accounts), func() error {
+				if forceImportDesc {
+					return b.ReimportAccounts(accounts)
+				}
+
+				return b.importNewWallet(accounts)
+			})
+
+			if errors.Is(err, ErrJobCancelled) {
+				// The import was cancelled through the control API. Do not
+				// checkpoint the rescan, so that it resumes on next start.
+				b.mustTransition(StateIncomplete, "import cancelled")
+				importDone <- true
+				return
+			}
+
+			if err != nil {
 				log.WithFields(log.Fields{
 					"prefix": "worker",
 					"error":  err,
 				}).Error("Failed while importing descriptors")
 
-				sendInterruptSignal()
+				fail("import descriptors")
 				return
 			}
 
-			b.IsPendingScan = false
-
 		} else {
 			// wallet is loaded and exists in the backend
-			err := b.checkWalletSyncStatus()
+			scanning, err := b.walletScanning()
 			if err != nil {
 				log.WithFields(log.Fields{
 					"prefix": "worker",
 					"error":  err,
 				}).Error("failed to check wallet status")
 
-				sendInterruptSignal()
+				fail("wallet status")
 				return
 			}
 
-			if b.IsPendingScan {
+			if scanning {
 				err := b.AbortRescan()
 				if err != nil {
 					log.WithFields(log.Fields{
@@ -345,20 +535,58 @@ func (b *Bus) Worker(config *config.Configuration, circulationCheck bool,
 				}
 			}
 
+			b.mustTransition(StateRescanning, "rescanning since last checkpoint")
+
 			endHeight, _ := b.GetBlockCount()
 
+			// Use the compact block filters, if available, to move the start
+			// of the rescan to the first block with possible wallet activity.
+			//
+			// This is only possible if all the descriptors in the wallet are
+			// known from the account configuration.
+			if accounts := config.ActiveAccounts(); b.BlockFilter && len(accounts) > 0 {
+				startHeight, err = b.filterRescanStart(accounts, startHeight, endHeight)
+				if err != nil {
+					log.WithFields(log.Fields{
+						"prefix": "worker",
+						"error":  err,
+					}).Warn("Failed to scan compact block filters")
+				}
+			}
+
 			// Begin Starting rescan, this is a blocking call
-			err = b.rescanWallet(startHeight, endHeight)
-			if err != nil {
+			if startHeight != -1 {
+				err = b.Jobs.Run(JobRescan, nil, func() error {
+					// The rescan starts from the last checkpoint, so its
+					// progress can be checkpointed.
+					return b.rescanWallet(startHeight, endHeight, true)
+				})
+
+				if errors.Is(err, ErrJobCancelled) {
+					// The rescan was cancelled through the control API. Do
+					// not checkpoint it, so that it resumes on next start.
+					b.mustTransition(StateIncomplete, "rescan cancelled")
+					importDone <- true
+					return
+				}
+
+				if err != nil {
+					log.WithFields(log.Fields{
+						"prefix": "worker",
+						"error":  err,
+					}).Error("Failed to rescan blocks")
+					fail("rescan wallet")
+					return
+				}
+			} else {
 				log.WithFields(log.Fields{
 					"prefix": "worker",
-					"error":  err,
-				}).Error("Failed to rescan blocks")
-				sendInterruptSignal()
-				return
+				}).Info("No wallet activity found in compact block filters; skipping rescan")
 			}
 		}
 
+		b.mustTransition(StateReady, "startup scan complete")
+
 		err = b.DumpLatestRescanTime()
 		if err != nil {
 			log.WithFields(log.Fields{
@@ -368,6 +596,8 @@ func (b *Bus) Worker(config *config.Configuration, circulationCheck bool,
 		}
 
 		importDone <- true
+
+		b.MonitorGapLimit(config)
 	}()
 
 	go func() {
@@ -400,3 +630,54 @@ func (b *Bus) Worker(config *config.Configuration, circulationCheck bool,
 		}
 	}()
 }
+
+// AccountDepth returns the number of addresses imported for the account,
+// taking the default depth into account.
+func AccountDepth(account config.Account) int {
+	if account.Depth == nil {
+		return defaultAccountDepth
+	}
+
+	return *account.Depth
+}
+
+// accountIDs returns the IDs of the given accounts.
+func accountIDs(accounts []config.Account) []string {
+	ids := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		ids = append(ids, account.ID())
+	}
+
+	return ids
+}
+
+// Reimport imports the descriptors of all the active accounts of the wallet
+// again, after aborting the scan of the wallet in progress if any, and saves
+// the rescan checkpoint. This is a blocking operation.
+func (b *Bus) Reimport(config *config.WalletConfig) error {
+	scanning, err := b.walletScanning()
+	if err != nil {
+		return err
+	}
+
+	if scanning {
+		if err := b.AbortRescan(); err != nil {
+			return err
+		}
+	}
+
+	if b.BlockFilter {
+		if err := b.DiscoverBirthdays(config); err != nil {
+			log.WithFields(log.Fields{
+				"prefix": "worker",
+				"error":  err,
+			}).Warn("Failed to discover account birthdays")
+		}
+	}
+
+	if err := b.ReimportAccounts(config.ActiveAccounts()); err != nil {
+		return err
+	}
+
+	return b.DumpLatestRescanTime()
+}
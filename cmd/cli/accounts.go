@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ledgerhq/satstack/bus"
+	"github.com/ledgerhq/satstack/httpd/svc"
+	"github.com/ledgerhq/satstack/types"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	accountsListCmd.Flags().String("wallet", "", "only list the accounts of the wallet with this name")
+	accountsListCmd.Flags().Bool("json", false, "print the accounts as JSON")
+
+	accountsCmd.AddCommand(accountsListCmd)
+
+	rootCmd.AddCommand(accountsCmd)
+}
+
+var accountsCmd = &cobra.Command{
+	Use:   "accounts",
+	Short: "Manage the accounts of lss.json.",
+}
+
+var accountsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the accounts, along with their import status.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName, _ := cmd.Flags().GetString("wallet")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		configuration, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		wallets, err := selectWallets(configuration, walletName)
+		if err != nil {
+			return err
+		}
+
+		result := make(map[string][]types.Account)
+		for _, wallet := range wallets {
+			// The accounts are only read, so the wallet is neither locked
+			// nor created, and can be listed while lss is running.
+			b, err := dialBus(configuration, wallet.Name)
+			if err != nil {
+				return err
+			}
+
+			s := &svc.Service{Bus: b, Config: wallet}
+			accounts, err := s.ListAccounts()
+			closeBus(b)
+
+			if bus.IsWalletNotLoaded(err) {
+				return withExitCode(exitNotReady, fmt.Errorf("wallet %s is not loaded, start lss to load it: %w",
+					wallet.Name, err))
+			} else if err != nil {
+				return withExitCode(exitNode, fmt.Errorf("wallet %s: %w", wallet.Name, err))
+			}
+
+			result[wallet.Name] = accounts
+		}
+
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(result)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "WALLET\tID\tTYPE\tSTATUS\tDEPTH\tBIRTHDAY\tEXTERNAL")
+
+		for _, wallet := range wallets {
+			for _, account := range result[wallet.Name] {
+				scriptType := account.ScriptType
+				if account.Threshold > 0 {
+					scriptType += fmt.Sprintf(" %d-of-%d", account.Threshold, len(account.Keys))
+				}
+
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", wallet.Name, account.ID, scriptType,
+					account.Status, account.Depth, account.Birthday, account.External)
+			}
+		}
+
+		return w.Flush()
+	},
+}
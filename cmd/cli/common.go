@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ledgerhq/satstack/bus"
+	"github.com/ledgerhq/satstack/config"
+)
+
+// Exit codes of lss commands, other than 0 for success.
+const (
+	// exitFailure is the exit code for errors without a more specific code.
+	exitFailure = 1
+
+	// exitConfig is the exit code used when the config file is missing or
+	// invalid.
+	exitConfig = 2
+
+	// exitNode is the exit code used when the Bitcoin node is unreachable,
+	// or cannot be used by SatStack.
+	exitNode = 3
+
+	// exitNotReady is the exit code used when a check command reports that
+	// SatStack or its wallets are not in a usable state.
+	exitNotReady = 4
+)
+
+// exitError is an error that terminates lss with a specific exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode wraps the given error, so that lss exits with the given code.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &exitError{code: code, err: err}
+}
+
+// loadConfig loads the configuration, and returns an error with exitConfig
+// as exit code on failure.
+//
+// The values of the config file are overridden by the LSS_* environment
+// variables, which are in turn overridden by the global flags.
+func loadConfig() (*config.Configuration, error) {
+	overrides, err := configOverrides()
+	if err != nil {
+		return nil, withExitCode(exitConfig, err)
+	}
+
+	configuration, err := config.Load(overrides)
+	if err != nil {
+		return nil, withExitCode(exitConfig, fmt.Errorf("failed to load config: %w", err))
+	}
+
+	return configuration, nil
+}
+
+// configOverrides returns the overrides of the config file set in the LSS_*
+// environment variables, and in the global flags, which take precedence.
+func configOverrides() (config.Overrides, error) {
+	env, err := config.EnvOverrides()
+	if err != nil {
+		return config.Overrides{}, err
+	}
+
+	flags := globalFlags
+
+	var o config.Overrides
+	o.Path, _ = flags.GetString("config")
+	o.KeyFile, _ = flags.GetString("keyfile")
+
+	for name, target := range map[string]**string{
+		"rpcurl":        &o.RPCURL,
+		"rpcuser":       &o.RPCUser,
+		"rpcpass":       &o.RPCPassword,
+		"rpccookiefile": &o.CookieFile,
+		"datadir":       &o.DataDir,
+		"torproxy":      &o.TorProxy,
+	} {
+		if flags.Changed(name) {
+			value, _ := flags.GetString(name)
+			*target = &value
+		}
+	}
+
+	if flags.Changed("notls") {
+		noTLS, _ := flags.GetBool("notls")
+		o.NoTLS = &noTLS
+	}
+
+	return env.Merge(o), nil
+}
+
+// selectWallets returns the wallets of the configuration with the given
+// name, or all the wallets if the name is empty.
+func selectWallets(configuration *config.Configuration, name string) ([]*config.WalletConfig, error) {
+	var wallets []*config.WalletConfig
+	for _, wallet := range configuration.Wallets() {
+		if name == "" || wallet.Name == name {
+			wallets = append(wallets, wallet)
+		}
+	}
+
+	if len(wallets) == 0 {
+		return nil, withExitCode(exitConfig, fmt.Errorf("unknown wallet: %s", name))
+	}
+
+	return wallets, nil
+}
+
+// rpcAuth returns the RPC user, password and cookie file used to connect to
+// bitcoind, and returns an error with exitConfig as exit code on failure.
+func rpcAuth(configuration *config.Configuration) (string, string, string, error) {
+	cookieFile, err := configuration.CookieFile()
+	if err != nil {
+		return "", "", "", withExitCode(exitConfig, fmt.Errorf("failed to locate cookie file: %w", err))
+	}
+
+	user, pass := configuration.Credentials()
+	return user, pass, cookieFile, nil
+}
+
+// newBus initializes a Bus for the given wallet, and returns an error with
+// exitNode as exit code on failure.
+//
+// The rescan checkpoint of the wallet is locked until the Bus is closed, so
+// that a command cannot unload the wallet of a running lss instance, and two
+// instances cannot update the same checkpoint. If the checkpoint is already
+// locked, an error with exitNotReady as exit code is returned.
+func newBus(configuration *config.Configuration, wallet string) (*bus.Bus, error) {
+	user, pass, cookieFile, err := rpcAuth(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := lockWallet(configuration, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := bus.New(
+		*configuration.RPCURL,
+		user,
+		pass,
+		cookieFile,
+		configuration.TorProxy,
+		configuration.NoTLS,
+		wallet,
+	)
+	if err != nil {
+		_ = lock.Unlock()
+		return nil, withExitCode(exitNode, fmt.Errorf("failed to initialize Bus: %w", err))
+	}
+
+	b.Config = configuration
+	b.CheckpointLock = lock
+
+	if configuration.PruneMode != "" {
+		b.PruneMode = configuration.PruneMode
+	}
+
+	return b, nil
+}
+
+// dialBus initializes a Bus for the given wallet like newBus, but without
+// loading or creating the wallet, nor locking its rescan checkpoint. It is
+// meant for read-only commands, which can run along with lss.
+func dialBus(configuration *config.Configuration, wallet string) (*bus.Bus, error) {
+	user, pass, cookieFile, err := rpcAuth(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := bus.Dial(
+		*configuration.RPCURL,
+		user,
+		pass,
+		cookieFile,
+		configuration.TorProxy,
+		configuration.NoTLS,
+		wallet,
+	)
+	if err != nil {
+		return nil, withExitCode(exitNode, fmt.Errorf("failed to initialize Bus: %w", err))
+	}
+
+	b.Config = configuration
+
+	return b, nil
+}
+
+// lockWallet locks the rescan checkpoint of the given wallet, and returns an
+// error with exitNotReady as exit code if it is already locked, usually by
+// a running lss instance.
+func lockWallet(configuration *config.Configuration, wallet string) (*config.Lock, error) {
+	lock, err := configuration.LockRescanConf(wallet)
+	if errors.Is(err, config.ErrLocked) {
+		return nil, withExitCode(exitNotReady, fmt.Errorf("wallet %s: %w", wallet, err))
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to lock rescan checkpoint: %w", err)
+	}
+
+	return lock, nil
+}
+
+// closeBus closes the given Bus, without waiting for more than 5s.
+func closeBus(b *bus.Bus) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	b.Close(ctx)
+}
@@ -0,0 +1,360 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ledgerhq/satstack/config"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	configInitCmd.Flags().String("scheme", "", "derivation scheme of xpub/tpub keys: legacy, segwit, native_segwit or taproot")
+	configInitCmd.Flags().String("fingerprint", "", "fingerprint of the master key, used in the key origin of descriptors (prompted for if missing)")
+	configInitCmd.Flags().String("ledger-live", "", "path to a Ledger Live account export (JSON)")
+	configInitCmd.Flags().String("network", "", "regtest or signet, for test keys used outside of testnet")
+	configInitCmd.Flags().String("rpcurl", "", "RPC URL of bitcoind (defaults to localhost, on the port of the network)")
+	configInitCmd.Flags().String("rpcuser", "", "RPC user of bitcoind")
+	configInitCmd.Flags().String("rpcpass", "", "RPC password of bitcoind")
+	configInitCmd.Flags().String("birthday", "", "birthday of the accounts (YYYY/MM/DD)")
+	configInitCmd.Flags().String("output", "", "path of the config file to write (defaults to ~/lss.json)")
+	configInitCmd.Flags().Bool("force", false, "overwrite the config file if it exists")
+
+	configMigrateCmd.Flags().Bool("dry-run", false, "print the migrated files instead of writing them")
+
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configMigrateCmd)
+	configCmd.AddCommand(configEncryptCmd)
+
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the lss.json config file.",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the config file, and exit with code 2 if it is invalid.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configuration, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		for _, wallet := range configuration.Wallets() {
+			fmt.Printf("Wallet %s: %d account(s)\n", wallet.Name, len(wallet.ActiveAccounts()))
+		}
+
+		fmt.Println("Config file is valid")
+
+		return nil
+	},
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the config file and the rescan checkpoints to the current schema version.",
+	Long: `Upgrade lss.json and the lss_rescan.json checkpoints of its wallets to the schema version of this ` +
+		`release. Older files are migrated in memory when loaded, but are only rewritten by this command, after ` +
+		`being copied to <file>.v<version>.bak. Use --dry-run to print the migrated files without writing them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		configuration, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		migrations, err := configuration.PendingMigrations()
+		if err != nil {
+			return withExitCode(exitConfig, err)
+		}
+
+		if len(migrations) == 0 {
+			fmt.Println("All files are up-to-date")
+			return nil
+		}
+
+		for _, migration := range migrations {
+			fmt.Printf("%s: version %d -> %d\n", migration.Path, migration.From, migration.To)
+
+			if dryRun {
+				fmt.Println(string(migration.Migrated))
+				continue
+			}
+
+			if err := migration.Apply(); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+var configEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the RPC credentials of the config file.",
+	Long: `Replace rpcuser and rpcpass in lss.json with a secrets section, encrypted with the passphrase of the ` +
+		`LSS_PASSPHRASE environment variable, or of the keyfile (~/.satstack/lss.key, or --keyfile). If neither ` +
+		`exists, a keyfile with a random passphrase is generated. The secrets are decrypted whenever the config ` +
+		`file is loaded, with the same passphrase.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configuration, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		overrides, err := configOverrides()
+		if err != nil {
+			return withExitCode(exitConfig, err)
+		}
+
+		passphrase, err := config.Passphrase(overrides.KeyFile)
+		if errors.Is(err, config.ErrPassphraseNotFound) {
+			keyFile := overrides.KeyFile
+			if keyFile == "" {
+				if keyFile, err = config.DefaultKeyFile(); err != nil {
+					return err
+				}
+			}
+
+			if err := config.GenerateKeyFile(keyFile); err != nil {
+				return fmt.Errorf("failed to generate keyfile: %w", err)
+			}
+
+			fmt.Printf("Generated keyfile %s, keep a backup of it to decrypt the config file\n", keyFile)
+
+			passphrase, err = config.Passphrase(keyFile)
+		}
+		if err != nil {
+			return withExitCode(exitConfig, err)
+		}
+
+		if err := configuration.Encrypt(passphrase); err != nil {
+			return withExitCode(exitConfig, err)
+		}
+
+		fmt.Println("RPC credentials encrypted")
+
+		return nil
+	},
+}
+
+// accountKey is an extended public key to generate an account from, along
+// with its derivation scheme if known.
+type accountKey struct {
+	xpub   string
+	scheme config.Scheme
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init [xpub...]",
+	Short: "Generate a config file from extended public keys.",
+	Long: `Generate the descriptors of accounts from their extended public keys (xpub, ypub, zpub, tpub, upub ` +
+		`or vpub), or from a Ledger Live account export, and write a validated config file for their network. ` +
+		`Missing keys, master key fingerprint and RPC credentials are prompted for.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scheme, _ := cmd.Flags().GetString("scheme")
+		fingerprint, _ := cmd.Flags().GetString("fingerprint")
+		ledgerLive, _ := cmd.Flags().GetString("ledger-live")
+		network, _ := cmd.Flags().GetString("network")
+		rpcURL, _ := cmd.Flags().GetString("rpcurl")
+		rpcUser, _ := cmd.Flags().GetString("rpcuser")
+		rpcPass, _ := cmd.Flags().GetString("rpcpass")
+		birthday, _ := cmd.Flags().GetString("birthday")
+		output, _ := cmd.Flags().GetString("output")
+		force, _ := cmd.Flags().GetBool("force")
+
+		in := bufio.NewReader(os.Stdin)
+
+		if output == "" {
+			home, err := homedir.Dir()
+			if err != nil {
+				return err
+			}
+
+			output = path.Join(home, "lss.json")
+		}
+
+		if _, err := os.Stat(output); err == nil && !force {
+			return withExitCode(exitConfig, fmt.Errorf("%s already exists, use --force to overwrite it", output))
+		}
+
+		var keys []accountKey
+		for _, xpub := range args {
+			keys = append(keys, accountKey{xpub: xpub, scheme: config.Scheme(scheme)})
+		}
+
+		if ledgerLive != "" {
+			data, err := os.ReadFile(ledgerLive)
+			if err != nil {
+				return err
+			}
+
+			exported, err := config.ParseLedgerLiveExport(data)
+			if err != nil {
+				return withExitCode(exitConfig, err)
+			}
+
+			for _, account := range exported {
+				accountScheme, err := account.Scheme()
+				if err != nil {
+					return withExitCode(exitConfig, fmt.Errorf("account %q: %w", account.Name, err))
+				}
+
+				keys = append(keys, accountKey{xpub: account.XPub, scheme: accountScheme})
+			}
+		}
+
+		if len(keys) == 0 {
+			for {
+				xpub, err := prompt(in, "Extended public key (empty to finish)", "")
+				if err != nil {
+					return err
+				}
+
+				if xpub == "" {
+					break
+				}
+
+				keys = append(keys, accountKey{xpub: xpub, scheme: config.Scheme(scheme)})
+			}
+		}
+
+		if len(keys) == 0 {
+			return withExitCode(exitConfig, errors.New("no account to configure"))
+		}
+
+		if fingerprint == "" {
+			var err error
+			if fingerprint, err = prompt(in, "Master key fingerprint (8 hex characters)", ""); err != nil {
+				return err
+			}
+		}
+
+		var accounts []config.Account
+		var chain string
+		seen := make(map[string]bool)
+
+		for _, key := range keys {
+			account, keyChain, err := config.AccountFromXPub(key.xpub, key.scheme, fingerprint)
+			if errors.Is(err, config.ErrUnknownScheme) {
+				// xpub and tpub keys are used by all schemes.
+				answer, perr := prompt(in, fmt.Sprintf("Scheme of %.12s... (legacy, segwit, native_segwit, taproot)",
+					key.xpub), string(config.SchemeNativeSegwit))
+				if perr != nil {
+					return perr
+				}
+
+				account, keyChain, err = config.AccountFromXPub(key.xpub, config.Scheme(answer), fingerprint)
+			}
+
+			if err != nil {
+				return withExitCode(exitConfig, err)
+			}
+
+			if chain != "" && keyChain != chain {
+				return withExitCode(exitConfig, errors.New("keys of different networks cannot be mixed"))
+			}
+			chain = keyChain
+
+			if seen[account.ID()] {
+				continue
+			}
+			seen[account.ID()] = true
+
+			if birthday != "" {
+				t, err := time.Parse("2006/01/02", birthday)
+				if err != nil {
+					return withExitCode(exitConfig, fmt.Errorf("invalid birthday: %s", birthday))
+				}
+
+				account.SetBirthday(t)
+			}
+
+			accounts = append(accounts, *account)
+		}
+
+		switch {
+		case network == "":
+			network = chain
+		case chain == "main" && network != "main", chain == "test" && network == "main":
+			return withExitCode(exitConfig, fmt.Errorf("keys are not valid on %s", network))
+		}
+
+		port, ok := config.DefaultRPCPorts[network]
+		if !ok {
+			return withExitCode(exitConfig, fmt.Errorf("unknown network: %s", network))
+		}
+
+		var err error
+		if rpcURL == "" {
+			if rpcURL, err = prompt(in, "RPC URL", fmt.Sprintf("localhost:%d", port)); err != nil {
+				return err
+			}
+		}
+
+		if rpcUser == "" {
+			if rpcUser, err = prompt(in, "RPC user (empty for cookie authentication)", ""); err != nil {
+				return err
+			}
+		}
+
+		if rpcUser != "" && rpcPass == "" {
+			if rpcPass, err = prompt(in, "RPC password", ""); err != nil {
+				return err
+			}
+		}
+
+		configuration := &config.Configuration{
+			Version:  config.CurrentVersion,
+			RPCURL:   &rpcURL,
+			NoTLS:    true,
+			Accounts: accounts,
+		}
+
+		if rpcUser != "" {
+			configuration.RPCUser, configuration.RPCPassword = &rpcUser, &rpcPass
+		}
+
+		if err := configuration.SaveAs(output); err != nil {
+			return withExitCode(exitConfig, err)
+		}
+
+		fmt.Printf("Wrote %d account(s) for %s to %s\n", len(accounts), network, output)
+
+		return nil
+	},
+}
+
+// prompt asks the user for a value on the standard output, and reads it from
+// the given reader. The default value is returned if the answer is empty.
+func prompt(in *bufio.Reader, label string, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Printf("%s [%s]: ", label, defaultValue)
+	} else {
+		fmt.Printf("%s: ", label)
+	}
+
+	answer, err := in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return defaultValue, nil
+	}
+
+	return answer, nil
+}
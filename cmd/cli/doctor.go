@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ledgerhq/satstack/bus"
+	"github.com/ledgerhq/satstack/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	doctorCmd.Flags().String("wallet", "", "only check the wallet with this name")
+	doctorCmd.Flags().Bool("repair", false, "import missing or incomplete descriptors again")
+	doctorCmd.Flags().Bool("recreate", false, "recreate wallets that cannot be repaired, and import all accounts "+
+		"into them (bitcoind must run on the same machine)")
+
+	rootCmd.AddCommand(doctorCmd)
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the integrity of the SatStack wallets.",
+	Long: `Compare the accounts of lss.json with the descriptors of the bitcoind wallets, check their ranges and ` +
+		`timestamps, and the consistency of the rescan checkpoints. Use --repair to import missing descriptors again, ` +
+		`and --recreate to replace wallets that cannot be repaired. Stop lss before repairing wallets. Exits with ` +
+		`code 4 if some wallets remain unhealthy.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName, _ := cmd.Flags().GetString("wallet")
+		repair, _ := cmd.Flags().GetBool("repair")
+		recreate, _ := cmd.Flags().GetBool("recreate")
+
+		configuration, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		wallets, err := selectWallets(configuration, walletName)
+		if err != nil {
+			return err
+		}
+
+		// The data directory is used to locate the wallets to recreate.
+		dataDir := configuration.DataDir
+		if dataDir == "" {
+			if dataDir, err = config.BitcoindDataDir(); err != nil {
+				return err
+			}
+		}
+
+		healthy := true
+		for _, wallet := range wallets {
+			ok, err := doctor(configuration, wallet, repair, recreate, dataDir)
+			if err != nil {
+				return fmt.Errorf("wallet %s: %w", wallet.Name, err)
+			}
+
+			healthy = healthy && ok
+		}
+
+		if !healthy {
+			return withExitCode(exitNotReady, errors.New("some wallets need to be repaired"))
+		}
+
+		return nil
+	},
+}
+
+// doctor checks the integrity of a wallet, and repairs it if requested. It
+// returns whether the wallet is healthy once done.
+func doctor(configuration *config.Configuration, wallet *config.WalletConfig,
+	repair bool, recreate bool, dataDir string) (bool, error) {
+	b, err := newBus(configuration, wallet.Name)
+	if err != nil {
+		return false, err
+	}
+
+	defer closeBus(b)
+
+	accounts := wallet.ActiveAccounts()
+
+	diagnosis, err := b.Diagnose(accounts)
+	if err != nil {
+		return false, err
+	}
+
+	printDiagnosis(diagnosis)
+
+	if diagnosis.Healthy() {
+		return true, nil
+	}
+
+	switch {
+	case recreate && diagnosis.NeedsRecreate():
+		log.WithField("wallet", b.Wallet).Info("Recreating wallet")
+		if err := b.RecreateWallet(b.WalletDir(dataDir), accounts); err != nil {
+			return false, err
+		}
+	case repair && !diagnosis.NeedsRecreate():
+		log.WithField("wallet", b.Wallet).Info("Repairing wallet")
+		if err := b.Repair(diagnosis); err != nil {
+			return false, err
+		}
+	default:
+		return false, nil
+	}
+
+	diagnosis, err = b.Diagnose(accounts)
+	if err != nil {
+		return false, err
+	}
+
+	printDiagnosis(diagnosis)
+
+	return diagnosis.Healthy(), nil
+}
+
+func printDiagnosis(d *bus.Diagnosis) {
+	fmt.Printf("Wallet %s:\n", d.Wallet)
+
+	if len(d.Findings) == 0 {
+		fmt.Println("  no issues found")
+		return
+	}
+
+	for _, finding := range d.Findings {
+		line := fmt.Sprintf("  [%s]", finding.Severity)
+		if finding.Account != "" {
+			line += fmt.Sprintf(" account %s:", finding.Account)
+		}
+
+		line += " " + finding.Message
+		if finding.Remedy != bus.RemedyNone {
+			line += fmt.Sprintf(" (fix with --%s)", finding.Remedy)
+		}
+
+		fmt.Println(line)
+	}
+}
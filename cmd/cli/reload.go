@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ledgerhq/satstack/config"
+	"github.com/ledgerhq/satstack/httpd/svc"
+	log "github.com/sirupsen/logrus"
+)
+
+// configCheckInterval indicates how often the config file is checked for
+// modifications.
+const configCheckInterval = 5 * time.Second
+
+// watchConfig reloads the config file whenever it is modified, or lss
+// receives SIGHUP, and imports the accounts added or updated in it.
+//
+// This is a blocking operation that returns once done is closed.
+func watchConfig(configuration *config.Configuration, services []*svc.Service, done <-chan struct{}) {
+	configPath := configuration.Path()
+	if configPath == "" {
+		// Without config file, the configuration cannot change.
+		return
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configCheckInterval)
+	defer ticker.Stop()
+
+	lastModTime := modTime(configPath)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-hup:
+			log.WithField("path", configPath).Info("SIGHUP received, reloading config file")
+		case <-ticker.C:
+			if modTime(configPath).Equal(lastModTime) {
+				continue
+			}
+		}
+
+		lastModTime = modTime(configPath)
+		if err := reloadConfig(configuration, services); errors.Is(err, config.ErrUnsavedChanges) {
+			// Changes made at runtime are about to be saved, which
+			// modifies the file again. Reload it at the next check
+			// regardless, in case saving them failed.
+			lastModTime = time.Time{}
+		}
+	}
+}
+
+// reloadConfig reloads the config file, and applies the changes of the
+// accounts to the services of their wallets. The previous configuration is
+// kept if the config file is invalid, or if the configuration has unsaved
+// changes.
+func reloadConfig(configuration *config.Configuration, services []*svc.Service) error {
+	changes, err := configuration.Reload()
+	if errors.Is(err, config.ErrUnsavedChanges) {
+		log.WithField("path", configuration.Path()).Debug("Config file reload postponed until changes are saved")
+		return err
+	} else if err != nil {
+		log.WithFields(log.Fields{
+			"path":  configuration.Path(),
+			"error": err,
+		}).Error("Failed to reload config file, keeping the previous one")
+		return err
+	}
+
+	for _, ch := range changes {
+		if ch.Empty() {
+			continue
+		}
+
+		for _, s := range services {
+			if s.Bus.Wallet != ch.Wallet {
+				continue
+			}
+
+			fields := log.Fields{
+				"wallet":  ch.Wallet,
+				"added":   len(ch.Added),
+				"updated": len(ch.Updated),
+				"removed": len(ch.Removed),
+			}
+
+			if job := s.ApplyAccountChanges(ch); job != "" {
+				fields["job"] = job
+			}
+
+			log.WithFields(fields).Info("Accounts reloaded from config file")
+		}
+	}
+
+	return nil
+}
+
+// modTime returns the modification time of the given file, or the zero time
+// if it cannot be read.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
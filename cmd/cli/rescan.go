@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rescanCmd.Flags().String("from", "", "height or date (YYYY/MM/DD) of the first block to rescan")
+	rescanCmd.Flags().Int64("to", -1, "height of the last block to rescan (defaults to the tip of the chain)")
+	rescanCmd.Flags().String("wallet", "", "only rescan the wallet with this name")
+	_ = rescanCmd.MarkFlagRequired("from")
+
+	rootCmd.AddCommand(rescanCmd)
+}
+
+var rescanCmd = &cobra.Command{
+	Use:   "rescan",
+	Short: "Rescan the wallets over a range of blocks.",
+	Long: `Rescan the wallets from the given height or date, up to the given height or the tip of the chain. ` +
+		`This is a blocking operation. Stop lss before running it.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetInt64("to")
+		walletName, _ := cmd.Flags().GetString("wallet")
+
+		configuration, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		wallets, err := selectWallets(configuration, walletName)
+		if err != nil {
+			return err
+		}
+
+		for _, wallet := range wallets {
+			b, err := newBus(configuration, wallet.Name)
+			if err != nil {
+				return err
+			}
+
+			err = func() error {
+				defer closeBus(b)
+
+				tip, err := b.GetBlockCount()
+				if err != nil {
+					return withExitCode(exitNode, err)
+				}
+
+				start, err := strconv.ParseInt(from, 10, 64)
+				if err != nil {
+					date, err := time.Parse("2006/01/02", from)
+					if err != nil {
+						return fmt.Errorf("invalid --from value: %s", from)
+					}
+
+					if start, err = b.GetHeightByTime(date); err != nil {
+						return withExitCode(exitNode, err)
+					}
+				}
+
+				end := to
+				if end == -1 {
+					end = tip
+				}
+
+				if start < 0 || end < start || end > tip {
+					return fmt.Errorf("invalid range [%d, %d] (tip at %d)", start, end, tip)
+				}
+
+				if err := b.RescanRange(start, end); err != nil {
+					return err
+				}
+
+				// The rescan checkpoint is only valid if the wallet is in
+				// sync up to the tip.
+				if end != tip {
+					return nil
+				}
+
+				return b.DumpLatestRescanTime()
+			}()
+
+			if err != nil {
+				return fmt.Errorf("wallet %s: %w", wallet.Name, err)
+			}
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/ledgerhq/satstack/bus"
+	"github.com/ledgerhq/satstack/config"
+	"github.com/ledgerhq/satstack/fortunes"
+	"github.com/ledgerhq/satstack/httpd"
+	"github.com/ledgerhq/satstack/httpd/svc"
+	"github.com/ledgerhq/satstack/version"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	addServeFlags(serveCmd)
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+// defaultPort is the port of the explorer API, unless configured otherwise.
+const defaultPort = "20000"
+
+// addServeFlags adds the flags of the serve command to the given command.
+func addServeFlags(cmd *cobra.Command) {
+	cmd.Flags().String("port", defaultPort, "port of the explorer API (env LSS_PORT)")
+	cmd.Flags().Bool("circulation-check", false, "performs inflation checks against the connected full node")
+	cmd.Flags().Bool("force-importdescriptors", false, "this will force importing descriptors although the wallet does already exist "+
+		"which will force the wallet to rescan from the brithday date")
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the explorer API to Ledger Live.",
+	Long: `Import the accounts of lss.json in the bitcoind wallets, keep them in sync, and serve the explorer API ` +
+		`to Ledger Live. This is the default command.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return serve(cmd)
+	},
+}
+
+// serve runs SatStack until it is interrupted. It returns an error if
+// SatStack could not start, or if one of the startup operations failed.
+func serve(cmd *cobra.Command) error {
+	port, _ := cmd.Flags().GetString("port")
+	circulationCheck, _ := cmd.Flags().GetBool("circulation-check")
+	forceImportDesc, _ := cmd.Flags().GetBool("force-importdescriptors")
+
+	configuration, services, err := startup(circulationCheck, forceImportDesc)
+	if err != nil {
+		return err
+	}
+
+	// The port flag takes precedence over LSS_PORT and the config file.
+	if !cmd.Flags().Changed("port") && configuration.Port != "" {
+		port = configuration.Port
+	}
+
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: httpd.GetHandler(services),
+	}
+
+	// Accounts added to the config file are imported without restarting.
+	reloadDone := make(chan struct{})
+	go watchConfig(configuration, services, reloadDone)
+
+	go func() {
+		// service connections
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.WithFields(log.Fields{
+				"error": err,
+			}).Fatal("Failed to listen and serve")
+		}
+	}()
+
+	// Wait for interrupt signal to gracefully shutdown the server with
+	// a timeout of 5 seconds.
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+
+	<-quit
+
+	log.Info("Shutdown server: in progress")
+
+	close(reloadDone)
+
+	var failed bool
+	for _, s := range services {
+		failed = failed || s.Bus.State.Current() == bus.StateFailed
+		shutdown(s)
+	}
+
+	{
+		// Scoped block to gracefully shutdown Gin-Gonic server within 10s.
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(ctx); err != nil {
+			log.WithField("error", err).Fatal("Failed to shutdown server")
+		}
+
+		log.Info("Shutdown server: done")
+	}
+
+	if failed {
+		return errors.New("startup operations failed")
+	}
+
+	return nil
+}
+
+// shutdown stops the scans of the wallet served by the given service, and
+// closes its Bus.
+func shutdown(s *svc.Service) {
+	// In case we are scanning the wallet, we have to abort the wallet
+	// because unloading the wallet while scanning will result in a timeout
+	// and a non recoverable state. This will be fixed by
+	// https://github.com/bitcoin/bitcoin/pull/26618
+	//
+	// The rescan checkpoint is only moved to the tip if the wallet was fully
+	// scanned, so that a failed or cancelled startup scan resumes on next
+	// start.
+	switch state := s.Bus.State.Current(); {
+	case s.Bus.State.Pending():
+		err := s.Bus.AbortRescan()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"wallet": s.Bus.Wallet,
+				"error":  err,
+			}).Error("Failed to abort rescan")
+		}
+	case state != bus.StateReady:
+		log.WithFields(log.Fields{
+			"prefix": "worker",
+			"wallet": s.Bus.Wallet,
+			"state":  state,
+		}).Warn("Wallet not fully scanned, rescan checkpoint left unchanged")
+	default:
+		err := s.Bus.DumpLatestRescanTime()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"prefix": "worker",
+				"wallet": s.Bus.Wallet,
+				"error":  err,
+			}).Error("Failed to dump latest block into file")
+		}
+	}
+
+	// Disconnect all connections, and stop all goroutines. If not
+	// successful within 5s, drop a nuclear bomb and fail with a FATAL error.
+	closeBus(s.Bus)
+}
+
+func startup(circulationCheck bool, forceImportDesc bool) (*config.Configuration, []*svc.Service, error) {
+	log.WithFields(log.Fields{
+		"build":   version.Build,
+		"commit":  version.GitCommit,
+		"runtime": version.GoVersion,
+		"arch":    version.OsArch,
+	}).Infof("Ledger SatStack (lss) %s", version.Version)
+
+	configuration, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var services []*svc.Service
+
+	// Each wallet gets its own Bus, connected to the corresponding bitcoind
+	// wallet, so that unrelated users on one node don't share a wallet.
+	for _, wallet := range configuration.Wallets() {
+		b, err := newBus(configuration, wallet.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		log.WithFields(log.Fields{
+			"wallet":      b.Wallet,
+			"chain":       b.Chain,
+			"pruned":      b.Pruned,
+			"txindex":     b.TxIndex,
+			"blockFilter": b.BlockFilter,
+		}).Info("RPC connection established")
+
+		services = append(services, &svc.Service{
+			Bus:    b,
+			Config: wallet,
+		})
+	}
+
+	fortunes.Fortune()
+
+	for _, s := range services {
+		s.Bus.Worker(s.Config, circulationCheck, forceImportDesc)
+	}
+
+	return configuration, services, nil
+}
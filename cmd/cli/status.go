@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ledgerhq/satstack/bus"
+	"github.com/ledgerhq/satstack/config"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	statusCmd.Flags().String("host", "127.0.0.1", "host where lss is listening")
+	statusCmd.Flags().String("port", defaultPort, "port where lss is listening (env LSS_PORT)")
+	statusCmd.Flags().String("wallet", "", "report the status of the wallet with this name")
+
+	rootCmd.AddCommand(statusCmd)
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Report the status of a running lss.",
+	Long: `Query the status endpoint of a running lss, and exit with code 0 if it is ready to serve ` +
+		`Ledger Live, or 4 otherwise.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host, _ := cmd.Flags().GetString("host")
+		port, _ := cmd.Flags().GetString("port")
+		walletName, _ := cmd.Flags().GetString("wallet")
+
+		if envPort := os.Getenv(config.EnvPort); envPort != "" && !cmd.Flags().Changed("port") {
+			port = envPort
+		}
+
+		req, err := http.NewRequest(http.MethodGet,
+			fmt.Sprintf("http://%s:%s/blockchain/v3/explorer/status", host, port), nil)
+		if err != nil {
+			return err
+		}
+
+		// Wallets are selected by their API key, if any.
+		if walletName != "" {
+			configuration, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			wallets, err := selectWallets(configuration, walletName)
+			if err != nil {
+				return err
+			}
+
+			if wallets[0].APIKey != "" {
+				req.Header.Set("X-API-Key", wallets[0].APIKey)
+			}
+		}
+
+		client := &http.Client{Timeout: 10 * time.Second}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return withExitCode(exitNotReady, fmt.Errorf("lss is not reachable: %w", err))
+		}
+
+		defer resp.Body.Close()
+
+		var status bus.ExplorerStatus
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			return withExitCode(exitNotReady, fmt.Errorf("malformed status: %w", err))
+		}
+
+		fmt.Printf("Status:       %s\n", status.Status)
+		fmt.Printf("Scan state:   %s\n", status.ScanState)
+		fmt.Printf("Version:      %s\n", status.Version)
+		fmt.Printf("Chain:        %s\n", status.Chain)
+		fmt.Printf("Pruned:       %t\n", status.Pruned)
+		fmt.Printf("TxIndex:      %t\n", status.TxIndex)
+		fmt.Printf("Block filter: %t\n", status.BlockFilter)
+
+		if status.SyncProgress != nil {
+			fmt.Printf("Sync:         %.2f%%\n", *status.SyncProgress)
+		}
+
+		if status.ScanProgress != nil {
+			fmt.Printf("Scan:         %.2f%%\n", *status.ScanProgress)
+		}
+
+		for _, warning := range status.Warnings {
+			fmt.Printf("Warning:      %s\n", warning)
+		}
+
+		if status.Status != bus.Ready {
+			return withExitCode(exitNotReady, fmt.Errorf("lss is not ready: %s", status.Status))
+		}
+
+		return nil
+	},
+}
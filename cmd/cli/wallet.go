@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	walletCmd.PersistentFlags().String("wallet", "", "only operate on the wallet with this name")
+
+	walletCmd.AddCommand(walletUnloadCmd)
+	walletCmd.AddCommand(walletReimportCmd)
+
+	rootCmd.AddCommand(walletCmd)
+}
+
+var walletCmd = &cobra.Command{
+	Use:   "wallet",
+	Short: "Manage the bitcoind wallets used by SatStack.",
+}
+
+var walletUnloadCmd = &cobra.Command{
+	Use:   "unload",
+	Short: "Unload the SatStack wallets from bitcoind.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName, _ := cmd.Flags().GetString("wallet")
+
+		return unloadWallets(walletName)
+	},
+}
+
+var walletReimportCmd = &cobra.Command{
+	Use:   "reimport",
+	Short: "Import all the account descriptors again, and rescan the wallets.",
+	Long: `Import the descriptors of all the accounts of lss.json again, which rescans the wallets from the ` +
+		`birthday of the accounts. This is a blocking operation, that can take hours. Stop lss before running it.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		walletName, _ := cmd.Flags().GetString("wallet")
+
+		configuration, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		wallets, err := selectWallets(configuration, walletName)
+		if err != nil {
+			return err
+		}
+
+		for _, wallet := range wallets {
+			b, err := newBus(configuration, wallet.Name)
+			if err != nil {
+				return err
+			}
+
+			err = b.Reimport(wallet)
+			closeBus(b)
+
+			if err != nil {
+				return fmt.Errorf("wallet %s: %w", wallet.Name, err)
+			}
+		}
+
+		return nil
+	},
+}
+
+// unloadWallets unloads the wallets with the given name, or all the wallets
+// of the configuration if the name is empty.
+//
+// Like newBus, it locks the rescan checkpoint of every wallet, so that the
+// wallets of a running lss instance are not unloaded.
+func unloadWallets(walletName string) error {
+	configuration, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	wallets, err := selectWallets(configuration, walletName)
+	if err != nil {
+		return err
+	}
+
+	for _, wallet := range wallets {
+		lock, err := lockWallet(configuration, wallet.Name)
+		if err != nil {
+			return err
+		}
+
+		b, err := dialBus(configuration, wallet.Name)
+		if err != nil {
+			_ = lock.Unlock()
+			return err
+		}
+
+		// The lock is released when the Bus is closed.
+		b.CheckpointLock = lock
+
+		err = b.UnloadWallet()
+		closeBus(b)
+
+		if err != nil {
+			return fmt.Errorf("wallet %s: %w", wallet.Name, err)
+		}
+	}
+
+	log.Info("Unload wallet: done")
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package config
+
+import "fmt"
+
+// DefaultWallet is the name of the bitcoind wallet holding the accounts at
+// the top level of the configuration.
+const DefaultWallet = "satstack"
+
+// WalletConfig is a view of the configuration, restricted to the accounts of
+// a single wallet. Other fields of the configuration remain accessible.
+//
+// The account methods of WalletConfig are safe for concurrent use, including
+// across views of the same configuration.
+type WalletConfig struct {
+	*Configuration
+
+	// Name is the name of the bitcoind wallet.
+	Name string
+
+	// APIKey, if set, is required to access the wallet through the HTTP
+	// API.
+	APIKey string
+}
+
+// Wallets returns a view of the configuration for every wallet, starting
+// with the default one.
+func (c *Configuration) Wallets() []*WalletConfig {
+	wallets := []*WalletConfig{{Configuration: c, Name: DefaultWallet}}
+
+	for _, wallet := range c.NamedWallets {
+		wallets = append(wallets, &WalletConfig{
+			Configuration: c,
+			Name:          wallet.Name,
+			APIKey:        wallet.APIKey,
+		})
+	}
+
+	return wallets
+}
+
+// accounts returns a pointer to the slice of accounts of the wallet. The
+// caller must hold the configuration lock.
+func (w *WalletConfig) accounts() (*[]Account, error) {
+	if w.Name == DefaultWallet {
+		return &w.Accounts, nil
+	}
+
+	for idx := range w.NamedWallets {
+		if w.NamedWallets[idx].Name == w.Name {
+			return &w.NamedWallets[idx].Accounts, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrWalletNotFound, w.Name)
+}
+
+// ActiveAccounts returns a copy of the accounts that have not been removed,
+// or none if the wallet is no longer in the configuration.
+func (w *WalletConfig) ActiveAccounts() []Account {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, err := w.accounts()
+	if err != nil {
+		return nil
+	}
+
+	var accounts []Account
+	for _, account := range *current {
+		if !account.Removed {
+			accounts = append(accounts, account)
+		}
+	}
+
+	return accounts
+}
+
+// AllAccounts returns a copy of all the accounts, including removed ones, or
+// none if the wallet is no longer in the configuration.
+func (w *WalletConfig) AllAccounts() []Account {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, err := w.accounts()
+	if err != nil {
+		return nil
+	}
+
+	return append([]Account(nil), *current...)
+}
+
+// AddAccounts appends the given accounts to the wallet. Accounts that were
+// removed are restored instead, with the given depth and birthday.
+//
+// Either all the accounts are added, or none: ErrAccountExists is returned
+// if one of them already exists, or is given twice.
+func (w *WalletConfig) AddAccounts(accounts []Account) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, err := w.accounts()
+	if err != nil {
+		return err
+	}
+
+	indexes := make(map[string]int, len(*current))
+	for idx, existing := range *current {
+		indexes[existing.ID()] = idx
+	}
+
+	added := make(map[string]bool, len(accounts))
+	for _, account := range accounts {
+		id := account.ID()
+		if idx, ok := indexes[id]; added[id] || (ok && !(*current)[idx].Removed) {
+			return fmt.Errorf("%w: %s", ErrAccountExists, id)
+		}
+
+		added[id] = true
+	}
+
+	for _, account := range accounts {
+		account.Removed = false
+
+		if idx, ok := indexes[account.ID()]; ok {
+			(*current)[idx] = account
+			continue
+		}
+
+		*current = append(*current, account)
+	}
+
+	return nil
+}
+
+// UpdateAccount applies the given function to the account with the given ID,
+// while holding the configuration lock. It returns false if no such account
+// exists.
+func (w *WalletConfig) UpdateAccount(id string, update func(*Account)) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, err := w.accounts()
+	if err != nil {
+		return false
+	}
+
+	accounts := *current
+	for idx := range accounts {
+		if accounts[idx].ID() == id {
+			update(&accounts[idx])
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,509 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+// descriptorInputCharset is the set of characters allowed in output
+// descriptors, ordered as required by the checksum algorithm.
+const descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+	"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+	"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+// descriptorChecksumCharset is the set of characters of descriptor
+// checksums.
+const descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// descriptorPolymod is the BCH code generator used to compute descriptor
+// checksums.
+func descriptorPolymod(c uint64, val uint64) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ val
+
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+
+	return c
+}
+
+// DescriptorChecksum computes the checksum of the given output descriptor,
+// which must not include a checksum already, as specified in BIP380.
+func DescriptorChecksum(desc string) (string, error) {
+	c := uint64(1)
+	cls := uint64(0)
+	clsCount := 0
+
+	for _, ch := range desc {
+		pos := strings.IndexRune(descriptorInputCharset, ch)
+		if pos == -1 {
+			return "", fmt.Errorf("invalid character in descriptor: %q", ch)
+		}
+
+		// Emit a symbol for the position inside the group, for every
+		// character.
+		c = descriptorPolymod(c, uint64(pos)&31)
+
+		// Accumulate the group numbers, and emit a symbol for every 3
+		// characters.
+		cls = cls*3 + uint64(pos>>5)
+		clsCount++
+		if clsCount == 3 {
+			c = descriptorPolymod(c, cls)
+			cls = 0
+			clsCount = 0
+		}
+	}
+
+	if clsCount > 0 {
+		c = descriptorPolymod(c, cls)
+	}
+
+	// Shift further to determine the checksum.
+	for j := 0; j < 8; j++ {
+		c = descriptorPolymod(c, 0)
+	}
+
+	// Prevent appending zeroes from not affecting the checksum.
+	c ^= 1
+
+	checksum := make([]byte, 8)
+	for j := 0; j < 8; j++ {
+		checksum[j] = descriptorChecksumCharset[(c>>(5*(7-j)))&31]
+	}
+
+	return string(checksum), nil
+}
+
+// WithChecksum returns the given output descriptor, followed by its
+// checksum. Any checksum already present is replaced.
+func WithChecksum(desc string) (string, error) {
+	desc = strings.Split(desc, "#")[0]
+
+	checksum, err := DescriptorChecksum(desc)
+	if err != nil {
+		return "", err
+	}
+
+	return desc + "#" + checksum, nil
+}
+
+// ScriptType is the type of the outputs described by an output descriptor,
+// written as its script expressions without arguments.
+type ScriptType string
+
+const (
+	// ScriptTypePKH describes P2PKH outputs.
+	ScriptTypePKH ScriptType = "pkh"
+
+	// ScriptTypeSHWPKH describes P2WPKH outputs nested in P2SH outputs.
+	ScriptTypeSHWPKH ScriptType = "sh(wpkh)"
+
+	// ScriptTypeWPKH describes P2WPKH outputs.
+	ScriptTypeWPKH ScriptType = "wpkh"
+
+	// ScriptTypeTR describes P2TR outputs, spendable with the key path and
+	// optionally a script tree.
+	ScriptTypeTR ScriptType = "tr"
+
+	// ScriptTypeSHMulti and ScriptTypeSHSortedMulti describe legacy P2SH
+	// multisig outputs.
+	ScriptTypeSHMulti       ScriptType = "sh(multi)"
+	ScriptTypeSHSortedMulti ScriptType = "sh(sortedmulti)"
+
+	// ScriptTypeWSHMulti and ScriptTypeWSHSortedMulti describe P2WSH
+	// multisig outputs.
+	ScriptTypeWSHMulti       ScriptType = "wsh(multi)"
+	ScriptTypeWSHSortedMulti ScriptType = "wsh(sortedmulti)"
+
+	// ScriptTypeWSHMiniscript describes P2WSH outputs of any other miniscript
+	// policy, which is validated by bitcoind.
+	ScriptTypeWSHMiniscript ScriptType = "wsh(miniscript)"
+)
+
+// IsMultisig returns whether outputs of the script type are spent with the
+// keys of several cosigners.
+func (t ScriptType) IsMultisig() bool {
+	return strings.Contains(string(t), "multi") || strings.Contains(string(t), "miniscript")
+}
+
+// Maximum number of keys of multi() and sortedmulti() expressions, as
+// enforced by the consensus rules for P2SH, and by the standardness rules for
+// P2WSH.
+const (
+	maxMultisigKeysSH  = 15
+	maxMultisigKeysWSH = 20
+)
+
+// DescriptorScriptType returns the script type of the given output
+// descriptor, checksum included or not. It returns an error if the
+// descriptor is malformed, or if its script type is not supported.
+func DescriptorScriptType(desc string) (ScriptType, error) {
+	desc = strings.Split(desc, "#")[0]
+
+	name, args, err := splitScriptExpression(desc)
+	if err != nil {
+		return "", err
+	}
+
+	switch name {
+	case "pkh", "wpkh":
+		if err := validateKeyExpression(args); err != nil {
+			return "", err
+		}
+
+		return ScriptType(name), nil
+	case "sh":
+		inner, innerArgs, err := splitScriptExpression(args)
+		if err != nil {
+			return "", err
+		}
+
+		switch inner {
+		case "wpkh":
+			if err := validateKeyExpression(innerArgs); err != nil {
+				return "", err
+			}
+
+			return ScriptTypeSHWPKH, nil
+		case "multi", "sortedmulti":
+			if _, err := validateMultisig(innerArgs, maxMultisigKeysSH); err != nil {
+				return "", err
+			}
+
+			return ScriptType("sh(" + inner + ")"), nil
+		case "wsh":
+			scriptType, err := witnessScriptType(innerArgs)
+			if err != nil {
+				return "", err
+			}
+
+			return ScriptType("sh(" + string(scriptType) + ")"), nil
+		default:
+			return "", fmt.Errorf("unsupported script type: sh(%s)", inner)
+		}
+	case "wsh":
+		return witnessScriptType(args)
+	case "tr":
+		// The internal key may be followed by a script tree, which bitcoind
+		// validates on import.
+		key := strings.SplitN(args, ",", 2)[0]
+		if err := validateKeyExpression(key); err != nil {
+			return "", err
+		}
+
+		return ScriptTypeTR, nil
+	default:
+		return "", fmt.Errorf("unsupported script type: %s", name)
+	}
+}
+
+// witnessScriptType returns the script type of a P2WSH output, given the
+// script expression inside wsh().
+func witnessScriptType(script string) (ScriptType, error) {
+	name, args, err := splitScriptExpression(script)
+	if err != nil {
+		return "", err
+	}
+
+	switch name {
+	case "multi", "sortedmulti":
+		if _, err := validateMultisig(args, maxMultisigKeysWSH); err != nil {
+			return "", err
+		}
+
+		return ScriptType("wsh(" + name + ")"), nil
+	case "sh", "wsh", "wpkh", "tr", "combo", "addr", "raw", "rawtr":
+		return "", fmt.Errorf("%s() is not allowed inside wsh()", name)
+	default:
+		return ScriptTypeWSHMiniscript, nil
+	}
+}
+
+// validateMultisig checks the arguments of a multi() or sortedmulti()
+// expression, and returns the threshold.
+func validateMultisig(args string, maxKeys int) (int, error) {
+	parts := strings.Split(args, ",")
+
+	threshold, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid multisig threshold: %s", parts[0])
+	}
+
+	keys := parts[1:]
+	if len(keys) == 0 || len(keys) > maxKeys {
+		return 0, fmt.Errorf("invalid number of multisig keys: %d (max %d)", len(keys), maxKeys)
+	}
+
+	if threshold < 1 || threshold > len(keys) {
+		return 0, fmt.Errorf("invalid multisig threshold: %d of %d", threshold, len(keys))
+	}
+
+	for _, key := range keys {
+		if err := validateKeyExpression(key); err != nil {
+			return 0, err
+		}
+	}
+
+	return threshold, nil
+}
+
+// DescriptorThreshold returns the number of signatures required to spend the
+// outputs of a multi() or sortedmulti() descriptor, or 0 if the descriptor is
+// not of this form.
+func DescriptorThreshold(desc string) int {
+	expr := strings.Split(desc, "#")[0]
+
+	for {
+		name, args, err := splitScriptExpression(expr)
+		if err != nil {
+			return 0
+		}
+
+		switch name {
+		case "sh", "wsh":
+			expr = args
+		case "multi", "sortedmulti":
+			threshold, err := validateMultisig(args, maxMultisigKeysWSH)
+			if err != nil {
+				return 0
+			}
+
+			return threshold
+		default:
+			return 0
+		}
+	}
+}
+
+// KeyOrigin describes an extended public key of an output descriptor, and
+// where it was derived from.
+type KeyOrigin struct {
+	Fingerprint string // fingerprint of the master key, in hex
+	Path        string // derivation path from the master key, like m/48'/0'/0'/2'
+	XPub        string // extended public key
+}
+
+// keyExpressionRegexp matches the extended public keys of output
+// descriptors, along with their optional key origin.
+var keyExpressionRegexp = regexp.MustCompile(
+	`(?:\[([0-9a-fA-F]{8})((?:/[0-9]+['hH]?)*)\])?([xt]pub[1-9A-HJ-NP-Za-km-z]+)`)
+
+// DescriptorKeyOrigins returns the extended public keys of the given output
+// descriptor, in order of appearance. Multisig and miniscript descriptors
+// have one key per cosigner.
+func DescriptorKeyOrigins(desc string) []KeyOrigin {
+	var origins []KeyOrigin
+
+	for _, match := range keyExpressionRegexp.FindAllStringSubmatch(desc, -1) {
+		origin := KeyOrigin{
+			Fingerprint: strings.ToLower(match[1]),
+			XPub:        match[3],
+		}
+
+		if match[1] != "" {
+			origin.Path = "m" + strings.NewReplacer("h", "'", "H", "'").Replace(match[2])
+		}
+
+		origins = append(origins, origin)
+	}
+
+	return origins
+}
+
+// splitScriptExpression splits a script expression of the form NAME(ARGS)
+// into its name and arguments. Miniscript wrappers, like v: in v:pk(), are
+// part of the name.
+func splitScriptExpression(expr string) (string, string, error) {
+	open := strings.Index(expr, "(")
+	if open <= 0 || !strings.HasSuffix(expr, ")") {
+		return "", "", fmt.Errorf("malformed script expression: %s", expr)
+	}
+
+	args := expr[open+1 : len(expr)-1]
+
+	depth := 0
+	for _, ch := range args {
+		switch ch {
+		case '(', '{', '[':
+			depth++
+		case ')', '}', ']':
+			depth--
+		}
+
+		if depth < 0 {
+			break
+		}
+	}
+
+	if depth != 0 {
+		return "", "", fmt.Errorf("unbalanced brackets in script expression: %s", expr)
+	}
+
+	return expr[:open], args, nil
+}
+
+// validateKeyExpression checks that the given key expression is a single
+// key, and not a nested script expression.
+func validateKeyExpression(key string) error {
+	if key == "" {
+		return fmt.Errorf("missing key expression")
+	}
+
+	if strings.ContainsAny(key, "(),") {
+		return fmt.Errorf("unexpected script expression in place of a key: %s", key)
+	}
+
+	return nil
+}
+
+// extendedKeyRegexp matches the extended keys of output descriptors, private
+// or not, along with their optional key origin and derivation path.
+var extendedKeyRegexp = regexp.MustCompile(
+	`(?:\[([^\]]*)\])?([xt](?:pub|prv)[1-9A-HJ-NP-Za-km-z]+)((?:/[0-9]+['hH]?)*(?:/\*['hH]?)?)`)
+
+// keyOriginRegexp matches the contents of a key origin: a master key
+// fingerprint, followed by a derivation path.
+var keyOriginRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}((?:/[0-9]+['hH]?)*)$`)
+
+// validateDescriptorKeys checks the extended keys of the given output
+// descriptor: their key origin, their encoding, the chain they belong to
+// (unless empty), and their derivation path, which must end with
+// /<change>/*.
+//
+// It returns an error if the descriptor has no extended key, since the
+// addresses of the account could not be derived otherwise.
+func validateDescriptorKeys(desc string, chain string, change int) error {
+	matches := extendedKeyRegexp.FindAllStringSubmatch(desc, -1)
+	if len(matches) == 0 {
+		return fmt.Errorf("no extended public key")
+	}
+
+	for _, match := range matches {
+		origin, encoded, path := match[1], match[2], match[3]
+
+		short := encoded
+		if len(short) > 12 {
+			short = short[:12] + "..."
+		}
+
+		if strings.Contains(encoded[1:4], "prv") {
+			return fmt.Errorf("key %s: extended private keys are not allowed", short)
+		}
+
+		if strings.HasPrefix(match[0], "[") {
+			if !keyOriginRegexp.MatchString(origin) {
+				return fmt.Errorf("key %s: invalid key origin: [%s]", short, origin)
+			}
+
+			if err := validateDerivationPath(keyOriginRegexp.FindStringSubmatch(origin)[1]); err != nil {
+				return fmt.Errorf("key %s: key origin: %w", short, err)
+			}
+		}
+
+		key, err := hdkeychain.NewKeyFromString(encoded)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", short, err)
+		}
+
+		version, ok := extendedKeyVersions[hex.EncodeToString(key.Version())]
+		if !ok || version.scheme != "" {
+			return fmt.Errorf("key %s: only xpub and tpub keys are allowed in descriptors", short)
+		}
+
+		// Testnet keys are shared by all test chains.
+		if chain != "" && (version.chain == "main") != (chain == "main") {
+			return fmt.Errorf("key %s: not valid on chain %s", short, chain)
+		}
+
+		if strings.ContainsAny(path, "'hH") {
+			return fmt.Errorf("key %s: hardened derivation from an extended public key", short)
+		}
+
+		if err := validateDerivationPath(strings.TrimSuffix(path, "/*")); err != nil {
+			return fmt.Errorf("key %s: %w", short, err)
+		}
+
+		if suffix := fmt.Sprintf("/%d/*", change); !strings.HasSuffix(path, suffix) {
+			return fmt.Errorf("key %s: derivation path %s must end with %s", short, path, suffix)
+		}
+	}
+
+	return nil
+}
+
+// validateDerivationPath checks that the indexes of the given BIP32
+// derivation path, like /48'/0'/0'/2', are within bounds.
+func validateDerivationPath(path string) error {
+	for _, step := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if step == "" {
+			continue
+		}
+
+		index, err := strconv.ParseUint(strings.TrimRight(step, "'hH"), 10, 32)
+		if err != nil || index >= hdkeychain.HardenedKeyStart {
+			return fmt.Errorf("invalid derivation index: %s", step)
+		}
+	}
+
+	return nil
+}
+
+// changeAgnostic returns the given descriptor, without checksum, where the
+// change index of the derivation path of every extended key is replaced by a
+// placeholder, and hardened markers are normalized. The external and internal
+// descriptors of an account are equal once normalized.
+func changeAgnostic(desc string) string {
+	desc = strings.Split(desc, "#")[0]
+	desc = strings.NewReplacer("'", "h", "H", "h").Replace(desc)
+
+	return extendedKeyRegexp.ReplaceAllStringFunc(desc, func(key string) string {
+		i := strings.LastIndex(strings.TrimSuffix(key, "/*"), "/")
+		if i < 0 || !strings.HasSuffix(key, "/*") {
+			return key
+		}
+
+		return key[:i] + "/<change>/*"
+	})
+}
+
+// verifyChecksum checks the checksum of the given descriptor, if any.
+func verifyChecksum(desc string) error {
+	parts := strings.Split(desc, "#")
+	if len(parts) == 1 {
+		return nil
+	}
+
+	if len(parts) > 2 {
+		return fmt.Errorf("multiple checksums")
+	}
+
+	expected, err := DescriptorChecksum(parts[0])
+	if err != nil {
+		return err
+	}
+
+	if parts[1] != expected {
+		return fmt.Errorf("invalid checksum: %s (expected %s)", parts[1], expected)
+	}
+
+	return nil
+}
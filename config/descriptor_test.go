@@ -0,0 +1,87 @@
+package config
+
+import "testing"
+
+// xpub is the extended public key of the m/0' chain of the BIP32 test
+// vector 1.
+const xpub = "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw"
+
+func TestDescriptorScriptType(t *testing.T) {
+	tests := []struct {
+		name     string
+		desc     string
+		expected ScriptType
+		invalid  bool
+	}{
+		{
+			name:     "pkh",
+			desc:     "pkh([3442193e/44h/0h/0h]" + xpub + "/0/*)",
+			expected: ScriptTypePKH,
+		},
+		{
+			name:     "sh(wpkh)",
+			desc:     "sh(wpkh([3442193e/49h/0h/0h]" + xpub + "/0/*))",
+			expected: ScriptTypeSHWPKH,
+		},
+		{
+			name:     "wpkh",
+			desc:     "wpkh([3442193e/84h/0h/0h]" + xpub + "/0/*)",
+			expected: ScriptTypeWPKH,
+		},
+		{
+			name:     "tr",
+			desc:     "tr([3442193e/86h/0h/0h]" + xpub + "/0/*)",
+			expected: ScriptTypeTR,
+		},
+		{
+			name:     "tr with checksum",
+			desc:     "tr([3442193e/86h/0h/0h]" + xpub + "/0/*)#00000000",
+			expected: ScriptTypeTR,
+		},
+		{
+			name:     "tr with script tree",
+			desc:     "tr([3442193e/86h/0h/0h]" + xpub + "/0/*,pk(" + xpub + "/1/*))",
+			expected: ScriptTypeTR,
+		},
+		{
+			name:    "tr without key",
+			desc:    "tr()",
+			invalid: true,
+		},
+		{
+			name:    "tr with script in place of key",
+			desc:    "tr(pk(" + xpub + "/0/*))",
+			invalid: true,
+		},
+		{
+			name:    "tr inside wsh",
+			desc:    "wsh(tr(" + xpub + "/0/*))",
+			invalid: true,
+		},
+		{
+			name:    "unsupported",
+			desc:    "rawtr(" + xpub + "/0/*)",
+			invalid: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scriptType, err := DescriptorScriptType(test.desc)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("got %q, want an error", scriptType)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if scriptType != test.expected {
+				t.Errorf("got %q, want %q", scriptType, test.expected)
+			}
+		})
+	}
+}
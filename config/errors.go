@@ -16,9 +16,47 @@ var (
 	// error.
 	ErrMalformed = errors.New("malformed JSON")
 
+	// ErrUnsupportedVersion indicates that a file was written by a newer
+	// version of SatStack, with a schema that cannot be read safely.
+	ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+	// ErrWalletNotFound indicates that a view of the configuration refers
+	// to a wallet that is no longer in it.
+	ErrWalletNotFound = errors.New("wallet not found in the configuration")
+
+	// ErrAccountExists indicates that an account being added already exists
+	// in the wallet.
+	ErrAccountExists = errors.New("account already exists")
+
+	// ErrUnknownScheme indicates that the derivation scheme of an extended
+	// public key cannot be inferred from its version, and must be given.
+	ErrUnknownScheme = errors.New("cannot infer the derivation scheme")
+
+	// ErrUnsavedChanges indicates that the configuration was changed at
+	// runtime, but not saved yet, so the config file cannot be reloaded
+	// without losing the changes.
+	ErrUnsavedChanges = errors.New("configuration has unsaved changes")
+
+	// ErrInternalDescriptorChanged indicates that the internal descriptor of
+	// an account was changed in the config file, but not its external
+	// descriptor, which identifies the account. The descriptors imported in
+	// the bitcoind wallet cannot be changed.
+	ErrInternalDescriptorChanged = errors.New("internal descriptor of account changed")
+
 	// ErrValidation indicates a validation error in the config.
 	ErrValidation = errors.New("validation error")
 
+	// ErrPassphraseNotFound indicates that the config file has encrypted
+	// secrets, but neither LSS_PASSPHRASE nor a keyfile is set.
+	ErrPassphraseNotFound = errors.New("passphrase not found, set LSS_PASSPHRASE or a keyfile")
+
+	// ErrDecrypt indicates that the encrypted secrets of the config file
+	// could not be decrypted, usually because of a wrong passphrase.
+	ErrDecrypt = errors.New("failed to decrypt secrets")
+
+	// ErrLocked indicates that a file is locked by another lss instance.
+	ErrLocked = errors.New("locked by another lss instance")
+
 	// ErrHomeNotFound indicates that an error was encountered while obtaining
 	// the user's home directory.
 	ErrHomeNotFound = errors.New("home directory not found")
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// LedgerLiveAccount models a Bitcoin account exported from Ledger Live.
+type LedgerLiveAccount struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	CurrencyID     string `json:"currencyId"`
+	DerivationMode string `json:"derivationMode"`
+	XPub           string `json:"xpub"`
+}
+
+// Scheme returns the derivation scheme of the account.
+func (a LedgerLiveAccount) Scheme() (Scheme, error) {
+	switch a.DerivationMode {
+	case "":
+		return SchemeLegacy, nil
+	case string(SchemeSegwit), string(SchemeNativeSegwit), string(SchemeTaproot):
+		return Scheme(a.DerivationMode), nil
+	default:
+		return "", fmt.Errorf("unsupported derivation mode: %s", a.DerivationMode)
+	}
+}
+
+// ParseLedgerLiveExport extracts the Bitcoin accounts of a Ledger Live
+// export. The export can either be a list of accounts, an object with an
+// "accounts" list, or the app.json file of Ledger Live, where accounts are
+// nested in "data" objects.
+//
+// Accounts of other currencies are skipped.
+func ParseLedgerLiveExport(data []byte) ([]LedgerLiveAccount, error) {
+	var export struct {
+		Accounts []json.RawMessage `json:"accounts"`
+		Data     *struct {
+			Accounts []json.RawMessage `json:"accounts"`
+		} `json:"data"`
+	}
+
+	raws := []json.RawMessage{}
+	if err := json.Unmarshal(data, &raws); err != nil {
+		if err := json.Unmarshal(data, &export); err != nil {
+			return nil, fmt.Errorf("%s: %w", ErrMalformed, err)
+		}
+
+		raws = export.Accounts
+		if export.Data != nil {
+			raws = append(raws, export.Data.Accounts...)
+		}
+	}
+
+	var accounts []LedgerLiveAccount
+	for _, raw := range raws {
+		var wrapper struct {
+			Data *LedgerLiveAccount `json:"data"`
+		}
+
+		if err := json.Unmarshal(raw, &wrapper); err != nil {
+			return nil, fmt.Errorf("%s: %w", ErrMalformed, err)
+		}
+
+		var account LedgerLiveAccount
+		if wrapper.Data != nil {
+			account = *wrapper.Data
+		} else if err := json.Unmarshal(raw, &account); err != nil {
+			return nil, fmt.Errorf("%s: %w", ErrMalformed, err)
+		}
+
+		// Account IDs are of the form js:2:<currency>:<xpub>:<mode>, and
+		// can be used if the other fields are missing.
+		if parts := strings.Split(account.ID, ":"); len(parts) == 5 && parts[0] == "js" {
+			if account.CurrencyID == "" {
+				account.CurrencyID = parts[2]
+			}
+
+			if account.XPub == "" {
+				account.XPub = parts[3]
+				account.DerivationMode = parts[4]
+			}
+		}
+
+		if account.CurrencyID != "bitcoin" && account.CurrencyID != "bitcoin_testnet" {
+			continue
+		}
+
+		if account.XPub == "" {
+			return nil, fmt.Errorf("%s: account %q has no xpub", ErrMalformed, account.Name)
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
@@ -1,10 +1,11 @@
 package config
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"runtime"
 
 	log "github.com/sirupsen/logrus"
@@ -12,75 +13,118 @@ import (
 	"github.com/mitchellh/go-homedir"
 )
 
-// Load reads the config file from disk and returns a Configuration.
+// Load reads the config file from disk, applies the given overrides, and
+// returns a Configuration.
 //
-// It searches for the config file in a standard set of directories, in the
-// following order:
+// Unless the overrides specify its path, it searches for the config file in a
+// standard set of directories, in the following order:
 //  1. Ledger Live user data folder.
 //  2. Current directory.
 //  3. User's home directory.
 //
-// The filename is always expected to be lss.json.
-func Load() (*Configuration, error) {
-	paths, err := configLookupPaths()
-	if err != nil {
-		return nil, err
-	}
+// The filename is always expected to be lss.json. The config file can be
+// omitted if the overrides include the RPC URL and credentials, in which case
+// the configuration has no accounts, and rescan checkpoints are kept in the
+// current directory.
+func Load(overrides Overrides) (*Configuration, error) {
+	var rescanDir string
+
+	configPath := overrides.Path
+	if configPath != "" {
+		if !fileExists(configPath) {
+			return nil, fmt.Errorf("%w: %s", ErrConfigFileNotFound, configPath)
+		}
 
-	var configPath string
-	for _, maybePath := range paths {
-		if fileExists(maybePath) {
-			configPath = maybePath
-			break
+		rescanDir = filepath.Dir(configPath)
+	} else {
+		paths, err := configLookupPaths()
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	if configPath == "" {
-		return nil, ErrConfigFileNotFound
+		for _, maybePath := range paths {
+			if fileExists(maybePath) {
+				configPath = maybePath
+				break
+			}
+		}
 	}
 
-	log.WithField("path", configPath).Info("Config file detected")
+	var configuration *Configuration
 
-	configuration, err := loadFromPath(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", ErrMalformed, err)
+	switch {
+	case configPath != "":
+		log.WithField("path", configPath).Info("Config file detected")
+
+		var err error
+		if configuration, err = loadFile(configPath, overrides.KeyFile); err != nil {
+			return nil, err
+		}
+	case overrides.hasRPC():
+		log.Info("No config file, using the environment and flags only")
+
+		configuration = &Configuration{Version: CurrentVersion}
+		rescanDir = "."
+	default:
+		return nil, ErrConfigFileNotFound
 	}
 
+	configuration.rescanDir = rescanDir
+	configuration.overrides = overrides
+	configuration.replaced = overrides.apply(configuration)
+
 	if err := configuration.validate(); err != nil {
 		return nil, fmt.Errorf("%s: %w", ErrValidation, err)
 	}
 
+	if configuration.path != "" {
+		saved, err := configuration.savedForm()
+		if err != nil {
+			return nil, err
+		}
+
+		configuration.saved = saved
+	}
+
 	return configuration, nil
 }
 
-func LoadRescanConf() (*ConfigurationRescan, error) {
-	paths, err := configRescanLookupPaths()
+// LoadRescanConf reads the rescan checkpoint of the given wallet from disk.
+func (c *Configuration) LoadRescanConf(wallet string) (*ConfigurationRescan, error) {
+	configPath, err := c.findRescanConf(wallet)
 	if err != nil {
 		return nil, err
 	}
 
-	var configPath string
-	for _, maybePath := range paths {
-		if fileExists(maybePath) {
-			configPath = maybePath
-			break
-		}
-	}
-
-	if configPath == "" {
-		return nil, ErrConfigFileNotFound
-	}
-
 	log.WithField("path", configPath).Info("Rescan Config file detected")
 
 	configuration, err := loadFromPathRescan(configPath)
-	if err != nil {
+	if errors.Is(err, ErrUnsupportedVersion) {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	} else if err != nil {
 		return nil, fmt.Errorf("%s: %w", ErrMalformed, err)
 	}
 
 	return configuration, nil
 }
 
+// findRescanConf returns the path of the rescan checkpoint of the given
+// wallet.
+func (c *Configuration) findRescanConf(wallet string) (string, error) {
+	paths, err := c.configRescanLookupPaths(wallet)
+	if err != nil {
+		return "", err
+	}
+
+	for _, maybePath := range paths {
+		if fileExists(maybePath) {
+			return maybePath, nil
+		}
+	}
+
+	return "", ErrConfigFileNotFound
+}
+
 // fileExists checks if a file exists and is not a directory before we
 // try using it to prevent further errors.
 func fileExists(filename string) bool {
@@ -92,50 +136,50 @@ func fileExists(filename string) bool {
 }
 
 func loadFromPath(path string) (*Configuration, error) {
-	file, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	defer func() {
-		err := file.Close()
-		if err != nil {
-			panic(err)
-		}
-	}()
-
-	decoder := json.NewDecoder(file)
-
-	configuration := &Configuration{}
-	err = decoder.Decode(configuration)
+	configuration, version, err := migrateConfig(data)
 	if err != nil {
 		return nil, err
 	}
 
+	if version < CurrentVersion {
+		log.WithFields(log.Fields{
+			"path":    path,
+			"version": version,
+			"latest":  CurrentVersion,
+		}).Warn("Config file uses an older schema, run `lss config migrate` to upgrade it")
+	}
+
+	// Keep the version of the file, so that saving the configuration does
+	// not upgrade it behind the back of the user.
+	configuration.Version = version
+
 	return configuration, nil
 }
 
 func loadFromPathRescan(path string) (*ConfigurationRescan, error) {
-	file, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	defer func() {
-		err := file.Close()
-		if err != nil {
-			panic(err)
-		}
-	}()
-
-	decoder := json.NewDecoder(file)
-
-	configuration := &ConfigurationRescan{}
-	err = decoder.Decode(configuration)
+	configuration, version, err := migrateRescanConf(data)
 	if err != nil {
 		return nil, err
 	}
 
+	if version < CurrentRescanVersion {
+		log.WithFields(log.Fields{
+			"path":    path,
+			"version": version,
+			"latest":  CurrentRescanVersion,
+		}).Debug("Rescan config file migrated in memory")
+	}
+
 	return configuration, nil
 }
 
@@ -153,20 +197,37 @@ func configLookupPaths() ([]string, error) {
 	}, nil
 }
 
-func configRescanLookupPaths() ([]string, error) {
+func (c *Configuration) configRescanLookupPaths(wallet string) ([]string, error) {
 	home, err := homedir.Dir()
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", ErrHomeNotFound, err)
 	}
 
+	filename := rescanFileName(wallet)
+
+	if c.rescanDir != "" {
+		return []string{filepath.Join(c.rescanDir, filename)}, nil
+	}
+
 	return []string{
-		path.Join(liveUserDataFolder(home), "lss_rescan.json"),
-		"lss_rescan.json",
-		path.Join(home, ".satstack", "lss_rescan.json"),
-		path.Join(home, "lss_rescan.json"),
+		path.Join(liveUserDataFolder(home), filename),
+		filename,
+		path.Join(home, ".satstack", filename),
+		path.Join(home, filename),
 	}, nil
 }
 
+// rescanFileName returns the name of the file holding the rescan checkpoint
+// of the given wallet. The default wallet uses lss_rescan.json, for backward
+// compatibility.
+func rescanFileName(wallet string) string {
+	if wallet == DefaultWallet {
+		return "lss_rescan.json"
+	}
+
+	return fmt.Sprintf("lss_rescan_%s.json", wallet)
+}
+
 func liveUserDataFolder(home string) string {
 	switch runtime.GOOS {
 	case "linux":
@@ -179,3 +240,21 @@ func liveUserDataFolder(home string) string {
 		return path.Join(home, ".config", "Ledger Live")
 	}
 }
+
+// BitcoindDataDir returns the default data directory of Bitcoin Core on the
+// current platform.
+func BitcoindDataDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ErrHomeNotFound, err)
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return path.Join(home, "Library", "Application Support", "Bitcoin"), nil
+	case "windows":
+		return path.Join(home, "AppData", "Roaming", "Bitcoin"), nil
+	default:
+		return path.Join(home, ".bitcoin"), nil
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Lock is an exclusive lock on a file, held until Unlock is called or the
+// process exits.
+type Lock struct {
+	file *os.File
+}
+
+// LockRescanConf acquires an exclusive lock on the rescan checkpoint of the
+// given wallet, so that two lss instances cannot update it concurrently. It
+// returns ErrLocked if another process holds the lock.
+//
+// The lock is held on a <checkpoint>.lock file, which records the PID of
+// its holder, and is left in place once released.
+func (c *Configuration) LockRescanConf(wallet string) (*Lock, error) {
+	configPath, err := c.rescanConfPath(wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	lockPath := configPath + ".lock"
+
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", lockPath, err)
+	}
+
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return &Lock{file: file}, nil
+}
+
+// Unlock releases the lock.
+func (l *Lock) Unlock() error {
+	if err := unlockFile(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+
+	return l.file.Close()
+}
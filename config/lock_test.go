@@ -0,0 +1,42 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLockRescanConf(t *testing.T) {
+	configuration := &Configuration{rescanDir: t.TempDir()}
+
+	lock, err := configuration.LockRescanConf(DefaultWallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := configuration.LockRescanConf(DefaultWallet); !errors.Is(err, ErrLocked) {
+		t.Fatalf("got %v, want %v", err, ErrLocked)
+	}
+
+	// Other wallets have their own lock.
+	other, err := configuration.LockRescanConf("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := other.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err = configuration.LockRescanConf(DefaultWallet)
+	if err != nil {
+		t.Fatalf("after unlock: %v", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
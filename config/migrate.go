@@ -0,0 +1,316 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CurrentVersion is the version of the schema of lss.json written by this
+// version of SatStack. Files without a version field are at version 0.
+const CurrentVersion = 1
+
+// CurrentRescanVersion is the version of the format of the rescan
+// checkpoints (lss_rescan.json) written by this version of SatStack.
+const CurrentRescanVersion = 1
+
+// migration upgrades a decoded JSON document by one version. It may mutate
+// the document in place.
+type migration func(doc map[string]interface{}) error
+
+// configMigrations lists the migrations of lss.json, where the migration at
+// index i upgrades a file from version i to version i+1.
+var configMigrations = []migration{
+	migrateConfigV0,
+}
+
+// rescanMigrations lists the migrations of the rescan checkpoints, where the
+// migration at index i upgrades a file from version i to version i+1.
+var rescanMigrations = []migration{
+	migrateRescanV0,
+}
+
+// migrateConfigV0 upgrades files written before the schema was versioned.
+//
+// Earlier versions accepted any depth, while bitcoind only imports ranges of
+// less than a million addresses. Accounts with a depth below 1 fall back to
+// the default depth, and larger depths are clamped to MaxDepth. Every
+// changed depth is logged, since the file itself is only rewritten by
+// `lss config migrate`.
+func migrateConfigV0(doc map[string]interface{}) error {
+	accountLists := []interface{}{doc["accounts"]}
+
+	if wallets, ok := doc["wallets"].([]interface{}); ok {
+		for _, wallet := range wallets {
+			if wallet, ok := wallet.(map[string]interface{}); ok {
+				accountLists = append(accountLists, wallet["accounts"])
+			}
+		}
+	}
+
+	for _, accounts := range accountLists {
+		accounts, ok := accounts.([]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, account := range accounts {
+			account, ok := account.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			depth, ok := account["depth"].(float64)
+			switch {
+			case !ok:
+			case depth < 1:
+				delete(account, "depth")
+
+				log.WithFields(log.Fields{
+					"descriptor": account["external"],
+					"depth":      depth,
+				}).Warn("Invalid account depth, using the default depth")
+			case depth > MaxDepth:
+				account["depth"] = MaxDepth
+
+				log.WithFields(log.Fields{
+					"descriptor": account["external"],
+					"depth":      int64(depth),
+					"max":        MaxDepth,
+				}).Warn("Account depth above the maximum, clamped")
+			}
+		}
+	}
+
+	return nil
+}
+
+// migrateRescanV0 upgrades checkpoints written before the format was
+// versioned.
+//
+// A checkpoint without a valid last block would be read as block 0, which
+// does not reflect any actual scan. It is marked as missing instead, so that
+// the descriptors are imported again.
+func migrateRescanV0(doc map[string]interface{}) error {
+	if lastBlock, ok := doc["last_block"].(float64); !ok || lastBlock < 0 {
+		doc["last_block"] = -1
+	}
+
+	return nil
+}
+
+// migrate applies the migrations to the given JSON document, from its
+// version up to the latest one. It returns the migrated document, and its
+// original version.
+func migrate(data []byte, migrations []migration) (map[string]interface{}, int, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, err
+	}
+
+	version := 0
+	if raw, ok := doc["version"]; ok {
+		v, ok := raw.(float64)
+		if !ok || v < 0 || v != float64(int(v)) {
+			return nil, 0, fmt.Errorf("invalid version: %v", raw)
+		}
+
+		version = int(v)
+	}
+
+	if version > len(migrations) {
+		return nil, 0, fmt.Errorf("%w: version %d, latest supported is %d",
+			ErrUnsupportedVersion, version, len(migrations))
+	}
+
+	for v := version; v < len(migrations); v++ {
+		if err := migrations[v](doc); err != nil {
+			return nil, 0, fmt.Errorf("migration from version %d: %w", v, err)
+		}
+
+		doc["version"] = v + 1
+	}
+
+	return doc, version, nil
+}
+
+// migrateConfig decodes the contents of lss.json, after upgrading them to
+// the current version. It returns the configuration, and the version of the
+// file.
+func migrateConfig(data []byte) (*Configuration, int, error) {
+	doc, version, err := migrate(data, configMigrations)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	configuration := &Configuration{}
+	if err := remarshal(doc, configuration); err != nil {
+		return nil, 0, err
+	}
+
+	return configuration, version, nil
+}
+
+// migrateRescanConf decodes the contents of a rescan checkpoint, after
+// upgrading them to the current version. It returns the checkpoint, and the
+// version of the file.
+func migrateRescanConf(data []byte) (*ConfigurationRescan, int, error) {
+	doc, version, err := migrate(data, rescanMigrations)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	checkpoint := &ConfigurationRescan{}
+	if err := remarshal(doc, checkpoint); err != nil {
+		return nil, 0, err
+	}
+
+	return checkpoint, version, nil
+}
+
+// remarshal decodes a generic JSON document into the given value.
+func remarshal(doc map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// patchMigrated returns the given file, with the values changed by its
+// migration to the given document patched in (see patchDocument), so that
+// the migrated file keeps its unknown keys, the order of its keys and its
+// indentation.
+func patchMigrated(data []byte, doc map[string]interface{}) ([]byte, error) {
+	var original map[string]interface{}
+	if err := json.Unmarshal(data, &original); err != nil {
+		return nil, err
+	}
+
+	old, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return patchDocument(data, old, migrated)
+}
+
+// Migration describes the upgrade of a file to the current version of its
+// schema.
+type Migration struct {
+	Path     string // path of the file
+	From     int    // version of the file
+	To       int    // version after migration
+	Migrated []byte // migrated contents of the file
+}
+
+// PendingMigrations returns the migrations required to upgrade lss.json and
+// the rescan checkpoints of its wallets to their current version. Files that
+// are up-to-date are skipped.
+func (c *Configuration) PendingMigrations() ([]Migration, error) {
+	if c.path == "" {
+		return nil, ErrConfigFileNotFound
+	}
+
+	var migrations []Migration
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, version, err := migrate(data, configMigrations)
+	if err == nil {
+		err = remarshal(doc, &Configuration{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.path, err)
+	}
+
+	if version < CurrentVersion {
+		migrated, err := patchMigrated(data, doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.path, err)
+		}
+
+		migrations = append(migrations, Migration{
+			Path:     c.path,
+			From:     version,
+			To:       CurrentVersion,
+			Migrated: migrated,
+		})
+	}
+
+	for _, wallet := range c.Wallets() {
+		path, err := c.findRescanConf(wallet.Name)
+		if err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		doc, version, err := migrate(data, rescanMigrations)
+		if err == nil {
+			err = remarshal(doc, &ConfigurationRescan{})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		if version == CurrentRescanVersion {
+			continue
+		}
+
+		migrated, err := patchMigrated(data, doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		migrations = append(migrations, Migration{
+			Path:     path,
+			From:     version,
+			To:       CurrentRescanVersion,
+			Migrated: migrated,
+		})
+	}
+
+	return migrations, nil
+}
+
+// Apply writes the migrated contents of the file, after copying the original
+// file to <path>.v<version>.bak.
+func (m Migration) Apply() error {
+	original, err := os.ReadFile(m.Path)
+	if err != nil {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", m.Path, m.From)
+	if err := os.WriteFile(backup, original, 0600); err != nil {
+		return err
+	}
+
+	if err := writeFile(m.Path, m.Migrated); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"path":   m.Path,
+		"from":   m.From,
+		"to":     m.To,
+		"backup": backup,
+	}).Info("File migrated")
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestPatchMigrated(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []migration
+		file       string
+		want       string
+	}{
+		{
+			name:       "config",
+			migrations: configMigrations,
+			file: `{
+    "rpcurl": "localhost:8332",
+    "comment": "kept",
+    "accounts": [
+        {
+            "external": "wpkh(xpub/0/*)",
+            "internal": "wpkh(xpub/1/*)",
+            "depth": 2000000
+        },
+        {
+            "depth": 500,
+            "external": "wpkh(xpub2/0/*)",
+            "internal": "wpkh(xpub2/1/*)"
+        }
+    ]
+}
+`,
+			want: `{
+    "rpcurl": "localhost:8332",
+    "comment": "kept",
+    "accounts": [
+        {
+            "external": "wpkh(xpub/0/*)",
+            "internal": "wpkh(xpub/1/*)",
+            "depth": 999999
+        },
+        {
+            "depth": 500,
+            "external": "wpkh(xpub2/0/*)",
+            "internal": "wpkh(xpub2/1/*)"
+        }
+    ],
+    "version": 1
+}
+`,
+		},
+		{
+			name:       "rescan checkpoint",
+			migrations: rescanMigrations,
+			file: `{
+ "last_block": -5,
+ "note": "kept"
+}`,
+			want: `{
+ "last_block": -1,
+ "note": "kept",
+ "version": 1
+}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, version, err := migrate([]byte(tt.file), tt.migrations)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if version != 0 {
+				t.Errorf("got version %d, want 0", version)
+			}
+
+			migrated, err := patchMigrated([]byte(tt.file), doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(migrated) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", migrated, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -9,10 +12,11 @@ import (
 //
 // Fields marked as (?) are optional.
 type Account struct {
-	External *string `json:"external"` // output descriptor at external path
-	Internal *string `json:"internal"` // output descriptor at internal path
-	Depth    *int    `json:"depth"`    // (?) Number of addresses to import
-	Birthday *date   `json:"birthday"` // (?) Earliest known creation date (YYYY/MM/DD)
+	External *string `json:"external"`           // output descriptor at external path
+	Internal *string `json:"internal"`           // output descriptor at internal path
+	Depth    *int    `json:"depth,omitempty"`    // (?) Number of addresses to import
+	Birthday *date   `json:"birthday,omitempty"` // (?) Earliest known creation date (YYYY/MM/DD)
+	Removed  bool    `json:"removed,omitempty"`  // (?) Whether the account was removed by the user
 }
 
 // Configuration is a struct to model the JSON configuration
@@ -20,27 +24,103 @@ type Account struct {
 //
 // Fields marked as (?) are optional.
 type Configuration struct {
-	RPCURL      *string   `json:"rpcurl"`
-	RPCUser     *string   `json:"rpcuser"`
-	RPCPassword *string   `json:"rpcpass"`
-	TorProxy    string    `json:"torproxy"`
-	NoTLS       bool      `json:"notls"`
-	Accounts    []Account `json:"accounts"`
+	Version     int     `json:"version"` // schema version, see CurrentVersion
+	RPCURL      *string `json:"rpcurl"`
+	RPCUser     *string `json:"rpcuser,omitempty"`
+	RPCPassword *string `json:"rpcpass,omitempty"`
+
+	// (?) RPC user and password encrypted with a passphrase, instead of
+	// rpcuser and rpcpass, see Configuration.Encrypt.
+	Secrets *Secrets `json:"secrets,omitempty"`
+
+	// (?) Cookie authentication, used instead of rpcuser and rpcpass: path
+	// of the cookie file of bitcoind, or its data directory to find it in.
+	// Without any credentials, the cookie file is looked up in the default
+	// data directory.
+	RPCCookieFile string `json:"rpccookiefile,omitempty"`
+	DataDir       string `json:"datadir,omitempty"`
+
+	TorProxy  string    `json:"torproxy"`
+	NoTLS     bool      `json:"notls"`
+	PruneMode string    `json:"prunemode,omitempty"` // (?) "refuse" (default) or "clamp"
+	Port      string    `json:"port,omitempty"`      // (?) port of the explorer API, defaults to 20000
+	Accounts  []Account `json:"accounts"`
+
+	// (?) Additional bitcoind wallets, each with their own accounts, for
+	// example to keep the accounts of different users apart.
+	NamedWallets []Wallet `json:"wallets,omitempty"`
+
+	// path is the location of the file the configuration was loaded from,
+	// used to persist changes made at runtime.
+	path string
+
+	// rescanDir is the directory of the rescan checkpoints, when the config
+	// file is not in one of the standard paths.
+	rescanDir string
+
+	// overrides are the values set outside of the config file, and
+	// replaced are the values of the config file they replaced, which Save
+	// persists instead.
+	overrides Overrides
+	replaced  Overrides
+
+	// saved is the configuration as last loaded from or saved to the config
+	// file, which Save compares against to patch only the changed values.
+	saved []byte
+
+	// mu guards the accounts against concurrent modification, by background
+	// workers and the control API.
+	mu sync.Mutex
+}
+
+// Wallet models the configuration of a named bitcoind wallet, in addition to
+// the default one.
+//
+// Fields marked as (?) are optional.
+type Wallet struct {
+	Name     string    `json:"name"`             // name of the wallet in bitcoind
+	APIKey   string    `json:"apikey,omitempty"` // (?) key required to access the wallet through the HTTP API
+	Accounts []Account `json:"accounts"`
 }
 
 // Type for saving the Rescan time to avoid scanning the wallet
 // always from the beginning
 type ConfigurationRescan struct {
+	Version         int    `json:"version"` // format version, see CurrentRescanVersion
 	LastSyncTime    string `json:"last_synctime"`
 	TimeStamp       string `json:"timestamp"`
 	LastBlock       int64  `json:"last_block"`
 	SatstackVersion string `json:"satstack_version"`
+
+	// (?) Unix time from which the wallet was rescanned, after its
+	// descriptors were imported with the current time as timestamp. It is
+	// earlier than the import timestamp of the descriptors.
+	ScannedSince int64 `json:"scanned_since,omitempty"`
+}
+
+// ID returns a stable identifier for the account, derived from its external
+// descriptor without checksum.
+func (a Account) ID() string {
+	external := strings.Split(*a.External, "#")[0]
+	hash := sha256.Sum256([]byte(external))
+	return hex.EncodeToString(hash[:8])
+}
+
+// SetBirthday sets the birthday of the account to the date of the given time,
+// in UTC.
+func (a *Account) SetBirthday(t time.Time) {
+	year, month, day := t.UTC().Date()
+	a.Birthday = &date{time.Date(year, month, day, 0, 0, 0, 0, time.UTC)}
 }
 
 type date struct {
 	time.Time
 }
 
+func (d date) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.Format("2006/01/02") + `"`), nil
+}
+
 func (d *date) UnmarshalJSON(input []byte) error {
 	strInput := string(input)
 	strInput = strings.Trim(strInput, `"`)
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultRPCPorts maps bitcoind chain names to the default RPC port.
+var DefaultRPCPorts = map[string]int{
+	"main":    8332,
+	"test":    18332,
+	"regtest": 18443,
+	"signet":  38332,
+}
+
+// Chain returns the bitcoind chain name implied by the port of the RPC URL,
+// or an empty string if the URL does not use a default port.
+//
+// It is only a hint, used to validate the configuration before connecting
+// to bitcoind, which reports the actual chain.
+func (c *Configuration) Chain() string {
+	if c.RPCURL == nil {
+		return ""
+	}
+
+	host := *c.RPCURL
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+
+	host = strings.SplitN(host, "/", 2)[0]
+
+	_, portStr, err := net.SplitHostPort(host)
+	if err != nil {
+		return ""
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return ""
+	}
+
+	for chain, defaultPort := range DefaultRPCPorts {
+		if port == defaultPort {
+			return chain
+		}
+	}
+
+	return ""
+}
+
+// ChainDataDir returns the directory of the given bitcoind chain, in the
+// given data directory. Files of the main chain are at the top level of the
+// data directory.
+func ChainDataDir(dataDir string, chain string) string {
+	switch chain {
+	case "test":
+		return filepath.Join(dataDir, "testnet3")
+	case "regtest", "signet":
+		return filepath.Join(dataDir, chain)
+	default:
+		return dataDir
+	}
+}
+
+// Credentials returns the RPC user and password, which are empty if cookie
+// authentication is used.
+func (c *Configuration) Credentials() (string, string) {
+	if c.RPCUser == nil || c.RPCPassword == nil {
+		return "", ""
+	}
+
+	return *c.RPCUser, *c.RPCPassword
+}
+
+// CookieFile returns the path of the cookie file of bitcoind, or an empty
+// string if the RPC user and password are set.
+//
+// Unless rpccookiefile is set, the cookie file is looked up in the
+// directory of the chain, inferred from rpcurl, in datadir, or in the
+// default data directory of bitcoind.
+func (c *Configuration) CookieFile() (string, error) {
+	if user, pass := c.Credentials(); user != "" || pass != "" {
+		return "", nil
+	}
+
+	if c.RPCCookieFile != "" {
+		return c.RPCCookieFile, nil
+	}
+
+	dataDir := c.DataDir
+	if dataDir == "" {
+		var err error
+		if dataDir, err = BitcoindDataDir(); err != nil {
+			return "", err
+		}
+	}
+
+	return filepath.Join(ChainDataDir(dataDir, c.Chain()), ".cookie"), nil
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Environment variables overriding the values of the config file.
+const (
+	EnvConfig      = "LSS_CONFIG"        // path of the config file
+	EnvKeyFile     = "LSS_KEYFILE"       // path of the keyfile of the secrets
+	EnvRPCURL      = "LSS_RPCURL"        // rpcurl
+	EnvRPCUser     = "LSS_RPCUSER"       // rpcuser
+	EnvRPCPassword = "LSS_RPCPASS"       // rpcpass
+	EnvCookieFile  = "LSS_RPCCOOKIEFILE" // rpccookiefile
+	EnvDataDir     = "LSS_DATADIR"       // datadir
+	EnvTorProxy    = "LSS_TORPROXY"      // torproxy
+	EnvNoTLS       = "LSS_NOTLS"         // notls, as a boolean like true or 1
+	EnvPort        = "LSS_PORT"          // port
+)
+
+// Overrides holds configuration values set outside of the config file,
+// through environment variables or command-line flags. Nil fields do not
+// override the values of the config file.
+//
+// Overrides are not persisted: Save writes back the values read from the
+// config file.
+type Overrides struct {
+	Path        string // path of the config file, instead of the standard ones
+	KeyFile     string // path of the keyfile of the secrets, instead of the default one
+	RPCURL      *string
+	RPCUser     *string
+	RPCPassword *string
+	CookieFile  *string
+	DataDir     *string
+	TorProxy    *string
+	NoTLS       *bool
+	Port        *string
+}
+
+// EnvOverrides returns the overrides set in the LSS_* environment variables.
+// Empty variables are ignored.
+func EnvOverrides() (Overrides, error) {
+	o := Overrides{
+		Path:        os.Getenv(EnvConfig),
+		KeyFile:     os.Getenv(EnvKeyFile),
+		RPCURL:      lookupEnv(EnvRPCURL),
+		RPCUser:     lookupEnv(EnvRPCUser),
+		RPCPassword: lookupEnv(EnvRPCPassword),
+		CookieFile:  lookupEnv(EnvCookieFile),
+		DataDir:     lookupEnv(EnvDataDir),
+		TorProxy:    lookupEnv(EnvTorProxy),
+		Port:        lookupEnv(EnvPort),
+	}
+
+	if value := lookupEnv(EnvNoTLS); value != nil {
+		noTLS, err := strconv.ParseBool(*value)
+		if err != nil {
+			return Overrides{}, fmt.Errorf("invalid %s: %s", EnvNoTLS, *value)
+		}
+
+		o.NoTLS = &noTLS
+	}
+
+	return o, nil
+}
+
+func lookupEnv(key string) *string {
+	if value := os.Getenv(key); value != "" {
+		return &value
+	}
+
+	return nil
+}
+
+// Merge returns the overrides, with the fields set in other taking
+// precedence.
+func (o Overrides) Merge(other Overrides) Overrides {
+	if other.Path != "" {
+		o.Path = other.Path
+	}
+	if other.KeyFile != "" {
+		o.KeyFile = other.KeyFile
+	}
+	if other.RPCURL != nil {
+		o.RPCURL = other.RPCURL
+	}
+	if other.RPCUser != nil {
+		o.RPCUser = other.RPCUser
+	}
+	if other.RPCPassword != nil {
+		o.RPCPassword = other.RPCPassword
+	}
+	if other.CookieFile != nil {
+		o.CookieFile = other.CookieFile
+	}
+	if other.DataDir != nil {
+		o.DataDir = other.DataDir
+	}
+	if other.TorProxy != nil {
+		o.TorProxy = other.TorProxy
+	}
+	if other.NoTLS != nil {
+		o.NoTLS = other.NoTLS
+	}
+	if other.Port != nil {
+		o.Port = other.Port
+	}
+
+	return o
+}
+
+// hasRPC returns whether the overrides are enough to connect to bitcoind,
+// without a config file. Without credentials, cookie authentication is
+// used.
+func (o Overrides) hasRPC() bool {
+	return o.RPCURL != nil
+}
+
+// apply sets the overridden values in the configuration, and returns the
+// values they replaced.
+func (o Overrides) apply(c *Configuration) Overrides {
+	var replaced Overrides
+
+	if o.RPCURL != nil {
+		replaced.RPCURL, c.RPCURL = c.RPCURL, o.RPCURL
+	}
+	if o.RPCUser != nil {
+		replaced.RPCUser, c.RPCUser = c.RPCUser, o.RPCUser
+	}
+	if o.RPCPassword != nil {
+		replaced.RPCPassword, c.RPCPassword = c.RPCPassword, o.RPCPassword
+	}
+	if o.CookieFile != nil {
+		previous := c.RPCCookieFile
+		replaced.CookieFile, c.RPCCookieFile = &previous, *o.CookieFile
+	}
+	if o.DataDir != nil {
+		previous := c.DataDir
+		replaced.DataDir, c.DataDir = &previous, *o.DataDir
+	}
+	if o.TorProxy != nil {
+		previous := c.TorProxy
+		replaced.TorProxy, c.TorProxy = &previous, *o.TorProxy
+	}
+	if o.NoTLS != nil {
+		previous := c.NoTLS
+		replaced.NoTLS, c.NoTLS = &previous, *o.NoTLS
+	}
+	if o.Port != nil {
+		previous := c.Port
+		replaced.Port, c.Port = &previous, *o.Port
+	}
+
+	return replaced
+}
+
+// restore sets the values of the config file replaced by the overrides, as
+// returned by apply, in the given configuration.
+func (o Overrides) restore(c *Configuration, replaced Overrides) {
+	if o.RPCURL != nil {
+		c.RPCURL = replaced.RPCURL
+	}
+	if o.RPCUser != nil {
+		c.RPCUser = replaced.RPCUser
+	}
+	if o.RPCPassword != nil {
+		c.RPCPassword = replaced.RPCPassword
+	}
+	if o.CookieFile != nil {
+		c.RPCCookieFile = *replaced.CookieFile
+	}
+	if o.DataDir != nil {
+		c.DataDir = *replaced.DataDir
+	}
+	if o.TorProxy != nil {
+		c.TorProxy = *replaced.TorProxy
+	}
+	if o.NoTLS != nil {
+		c.NoTLS = *replaced.NoTLS
+	}
+	if o.Port != nil {
+		c.Port = *replaced.Port
+	}
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestEnvOverrides(t *testing.T) {
+	for key, value := range map[string]string{
+		EnvConfig:      "/etc/lss.json",
+		EnvKeyFile:     "/etc/lss.key",
+		EnvRPCURL:      "node:8332",
+		EnvRPCUser:     "env-user",
+		EnvRPCPassword: "env-password",
+		EnvCookieFile:  "",
+		EnvDataDir:     "/var/lib/bitcoind",
+		EnvTorProxy:    "",
+		EnvNoTLS:       "1",
+		EnvPort:        "",
+	} {
+		t.Setenv(key, value)
+	}
+
+	overrides, err := EnvOverrides()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Empty variables do not override the config file.
+	expected := Overrides{
+		Path:        "/etc/lss.json",
+		KeyFile:     "/etc/lss.key",
+		RPCURL:      stringPtr("node:8332"),
+		RPCUser:     stringPtr("env-user"),
+		RPCPassword: stringPtr("env-password"),
+		DataDir:     stringPtr("/var/lib/bitcoind"),
+		NoTLS:       boolPtr(true),
+	}
+
+	if !reflect.DeepEqual(overrides, expected) {
+		t.Errorf("got %+v, want %+v", overrides, expected)
+	}
+
+	t.Setenv(EnvNoTLS, "maybe")
+	if _, err := EnvOverrides(); err == nil {
+		t.Errorf("invalid %s accepted", EnvNoTLS)
+	}
+}
+
+func TestOverridesMerge(t *testing.T) {
+	t.Setenv(EnvConfig, "")
+	t.Setenv(EnvKeyFile, "/etc/lss.key")
+	t.Setenv(EnvRPCURL, "node:8332")
+	t.Setenv(EnvRPCUser, "env-user")
+	t.Setenv(EnvNoTLS, "true")
+
+	env, err := EnvOverrides()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As with the global flags of lss, which override the LSS_* variables.
+	flags := Overrides{
+		KeyFile: "/home/user/lss.key",
+		RPCURL:  stringPtr("localhost:8332"),
+		NoTLS:   boolPtr(false),
+		Port:    stringPtr("20001"),
+	}
+
+	expected := Overrides{
+		KeyFile: "/home/user/lss.key",
+		RPCURL:  stringPtr("localhost:8332"),
+		RPCUser: stringPtr("env-user"),
+		NoTLS:   boolPtr(false),
+		Port:    stringPtr("20001"),
+	}
+
+	if merged := env.Merge(flags); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("got %+v, want %+v", merged, expected)
+	}
+}
+
+func TestSaveDoesNotWriteOverrides(t *testing.T) {
+	external := "wpkh([3442193e/84h/0h/0h]" + xpub + "/0/*)"
+	internal := "wpkh([3442193e/84h/0h/0h]" + xpub + "/1/*)"
+
+	path := filepath.Join(t.TempDir(), "lss.json")
+	data := []byte(`{
+  "version": 1,
+  "rpcurl": "localhost:8332",
+  "rpcuser": "user",
+  "rpcpass": "password",
+  "rpccookiefile": "/home/user/.bitcoin/.cookie",
+  "datadir": "/home/user/.bitcoin",
+  "torproxy": "socks5://127.0.0.1:9050",
+  "notls": true,
+  "port": "20000",
+  "accounts": [
+    {
+      "external": "` + external + `",
+      "internal": "` + internal + `"
+    }
+  ]
+}
+`)
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	overrides := Overrides{
+		Path:        path,
+		RPCURL:      stringPtr("node:8332"),
+		RPCUser:     stringPtr("other-user"),
+		RPCPassword: stringPtr("other-password"),
+		CookieFile:  stringPtr(""),
+		DataDir:     stringPtr("/var/lib/bitcoind"),
+		TorProxy:    stringPtr(""),
+		NoTLS:       boolPtr(false),
+		Port:        stringPtr("20001"),
+	}
+
+	configuration, err := Load(overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *configuration.RPCURL != "node:8332" || configuration.Port != "20001" || configuration.NoTLS {
+		t.Fatalf("overrides not applied: %+v", configuration)
+	}
+
+	// A change made at runtime is saved, but not the overridden values.
+	wallet := configuration.Wallets()[0]
+	wallet.UpdateAccount(wallet.AllAccounts()[0].ID(), func(account *Account) {
+		account.Removed = true
+	})
+
+	if err := configuration.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := bytes.Replace(data, []byte(`"internal": "`+internal+`"`),
+		[]byte(`"internal": "`+internal+`",`+"\n      "+`"removed": true`), 1)
+
+	if !bytes.Equal(saved, expected) {
+		t.Errorf("got:\n%s\nwant:\n%s", saved, expected)
+	}
+
+	// The overridden values are still in use.
+	if *configuration.RPCUser != "other-user" || configuration.DataDir != "/var/lib/bitcoind" {
+		t.Errorf("overrides lost after save: %+v", configuration)
+	}
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// jsonObject is a decoded JSON object that keeps the order of its keys, so
+// that patched config files keep the layout chosen by their authors.
+type jsonObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *jsonObject) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+
+	o.values[key] = value
+}
+
+func (o *jsonObject) delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+
+	delete(o.values, key)
+
+	for idx, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:idx], o.keys[idx+1:]...)
+			break
+		}
+	}
+}
+
+func (o *jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for idx, key := range o.keys {
+		if idx > 0 {
+			buf.WriteByte(',')
+		}
+
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// patchDocument applies the changes between two serializations of the
+// configuration, old and new, to the given config file, and returns the
+// patched file.
+//
+// Values that did not change are left as they are in the file, along with
+// the keys unknown to SatStack, the order of the keys and the indentation.
+func patchDocument(file []byte, old []byte, new []byte) ([]byte, error) {
+	fileDoc, err := decodeDocument(file)
+	if err != nil {
+		return nil, err
+	}
+
+	oldDoc, err := decodeDocument(old)
+	if err != nil {
+		return nil, err
+	}
+
+	newDoc, err := decodeDocument(new)
+	if err != nil {
+		return nil, err
+	}
+
+	compact, err := json.Marshal(patchValue(fileDoc, oldDoc, newDoc))
+	if err != nil {
+		return nil, err
+	}
+
+	var patched bytes.Buffer
+	if err := json.Indent(&patched, compact, "", documentIndent(file)); err != nil {
+		return nil, err
+	}
+
+	if bytes.HasSuffix(file, []byte("\n")) {
+		patched.WriteByte('\n')
+	}
+
+	return patched.Bytes(), nil
+}
+
+// patchValue returns the value of the file, with the changes from old to new
+// applied. Objects are patched key by key, and arrays index by index.
+func patchValue(file interface{}, old interface{}, new interface{}) interface{} {
+	if reflect.DeepEqual(old, new) {
+		return file
+	}
+
+	switch newValue := new.(type) {
+	case *jsonObject:
+		fileObject, ok := file.(*jsonObject)
+		oldObject, ok2 := old.(*jsonObject)
+		if !ok || !ok2 {
+			return new
+		}
+
+		for _, key := range newValue.keys {
+			oldValue, inOld := oldObject.values[key]
+			fileValue, inFile := fileObject.values[key]
+
+			switch {
+			case inOld && inFile:
+				fileObject.set(key, patchValue(fileValue, oldValue, newValue.values[key]))
+			case inOld && reflect.DeepEqual(oldValue, newValue.values[key]):
+				// Deleted from the file, and unchanged since.
+			default:
+				fileObject.set(key, newValue.values[key])
+			}
+		}
+
+		for _, key := range oldObject.keys {
+			if _, ok := newValue.values[key]; !ok {
+				fileObject.delete(key)
+			}
+		}
+
+		return fileObject
+	case []interface{}:
+		fileArray, ok := file.([]interface{})
+		oldArray, ok2 := old.([]interface{})
+		if !ok || !ok2 {
+			return new
+		}
+
+		patched := make([]interface{}, len(newValue))
+		for idx, value := range newValue {
+			if idx < len(oldArray) && idx < len(fileArray) {
+				patched[idx] = patchValue(fileArray[idx], oldArray[idx], value)
+			} else {
+				patched[idx] = value
+			}
+		}
+
+		return patched
+	default:
+		return new
+	}
+}
+
+// documentIndent returns the indentation of the given JSON document, taken
+// from its first indented line.
+func documentIndent(data []byte) string {
+	for _, line := range bytes.Split(data, []byte("\n"))[1:] {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) < len(line) {
+			return string(line[:len(line)-len(trimmed)])
+		}
+	}
+
+	return "  "
+}
+
+// decodeDocument decodes a JSON document, with objects decoded as jsonObject
+// and numbers as json.Number, so that it can be encoded again unchanged.
+func decodeDocument(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	value, err := decodeValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON document")
+	}
+
+	return value, nil
+}
+
+func decodeValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := &jsonObject{values: make(map[string]interface{})}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			object.set(key.(string), value)
+		}
+
+		// Consume the closing delimiter.
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+
+		return object, nil
+	case json.Delim('['):
+		array := []interface{}{}
+		for decoder.More() {
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			array = append(array, value)
+		}
+
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+
+		return array, nil
+	default:
+		return token, nil
+	}
+}
@@ -0,0 +1,334 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AccountChanges describes the changes of the accounts of a wallet, made to
+// the config file since it was last loaded.
+type AccountChanges struct {
+	Wallet string
+
+	// Added are the accounts new to the config file.
+	Added []Account
+
+	// Updated are the accounts whose depth changed, or which were restored
+	// after being removed, and must be imported again.
+	Updated []Account
+
+	// Removed are the IDs of the accounts deleted from the config file, or
+	// marked as removed.
+	Removed []string
+}
+
+// Empty returns whether there are no changes to apply to the wallet.
+func (ch AccountChanges) Empty() bool {
+	return len(ch.Added) == 0 && len(ch.Updated) == 0 && len(ch.Removed) == 0
+}
+
+// Path returns the path of the file the configuration was loaded from, or
+// an empty string if there is none.
+func (c *Configuration) Path() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.path
+}
+
+// Reload reads the config file again, and merges its accounts into the
+// configuration. It returns the changes of the accounts of every wallet,
+// which the caller is expected to import.
+//
+// Accounts deleted from the config file are marked as removed, since their
+// descriptors cannot be removed from the bitcoind wallets. Other settings,
+// as well as added or deleted wallets, only take effect after a restart:
+// they are logged, and kept so that Save does not revert them.
+//
+// Accounts are identified by their external descriptor, so changing only
+// the internal descriptor of an account returns an error, rather than being
+// silently ignored.
+//
+// The configuration lock is held throughout, so that the file cannot be
+// saved in the meantime. If the configuration was changed but not saved
+// yet, for example by the control API, ErrUnsavedChanges is returned
+// instead of reverting the changes, and the caller should retry once they
+// are saved.
+func (c *Configuration) Reload() ([]AccountChanges, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	configPath := c.path
+	if configPath == "" {
+		return nil, ErrConfigFileNotFound
+	}
+
+	current, err := c.savedForm()
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(current, c.saved) {
+		return nil, ErrUnsavedChanges
+	}
+
+	loaded, err := loadFile(configPath, c.overrides.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced := c.overrides.apply(loaded)
+
+	if err := loaded.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrValidation, err)
+	}
+
+	if err := checkInternalDescriptors(DefaultWallet, c.Accounts, loaded.Accounts); err != nil {
+		return nil, err
+	}
+
+	for _, wallet := range loaded.NamedWallets {
+		if idx := c.namedWallet(wallet.Name); idx >= 0 {
+			err := checkInternalDescriptors(wallet.Name, c.NamedWallets[idx].Accounts, wallet.Accounts)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	restart := c.settingChanges(loaded)
+
+	c.Version = loaded.Version
+	c.RPCURL, c.RPCUser, c.RPCPassword = loaded.RPCURL, loaded.RPCUser, loaded.RPCPassword
+	c.Secrets = loaded.Secrets
+	c.RPCCookieFile, c.DataDir = loaded.RPCCookieFile, loaded.DataDir
+	c.TorProxy, c.NoTLS = loaded.TorProxy, loaded.NoTLS
+	c.PruneMode, c.Port = loaded.PruneMode, loaded.Port
+	c.replaced = replaced
+
+	changes := []AccountChanges{mergeAccounts(DefaultWallet, &c.Accounts, loaded.Accounts)}
+
+	for _, wallet := range loaded.NamedWallets {
+		idx := c.namedWallet(wallet.Name)
+		if idx < 0 {
+			c.NamedWallets = append(c.NamedWallets, wallet)
+			restart = append(restart, fmt.Sprintf("wallets (%s added)", wallet.Name))
+			continue
+		}
+
+		if c.NamedWallets[idx].APIKey != wallet.APIKey {
+			c.NamedWallets[idx].APIKey = wallet.APIKey
+			restart = append(restart, fmt.Sprintf("wallets (%s apikey)", wallet.Name))
+		}
+
+		changes = append(changes, mergeAccounts(wallet.Name, &c.NamedWallets[idx].Accounts, wallet.Accounts))
+	}
+
+	for _, wallet := range c.NamedWallets {
+		if loaded.namedWallet(wallet.Name) < 0 {
+			// The wallet is still served, so its accounts are kept.
+			restart = append(restart, fmt.Sprintf("wallets (%s deleted)", wallet.Name))
+		}
+	}
+
+	// The configuration now matches the file, but for the accounts and
+	// wallets deleted from it, which Save must not write back.
+	saved, err := c.savedForm()
+	if err != nil {
+		return nil, err
+	}
+
+	c.saved = saved
+
+	for _, setting := range restart {
+		log.WithFields(log.Fields{
+			"path":    configPath,
+			"setting": setting,
+		}).Warn("Config setting changed, restart lss to apply it")
+	}
+
+	return changes, nil
+}
+
+// loadFile reads the config file at the given path, and decrypts its
+// secrets with the passphrase of the given keyfile, if any.
+func loadFile(configPath string, keyFile string) (*Configuration, error) {
+	configuration, err := loadFromPath(configPath)
+	if errors.Is(err, ErrUnsupportedVersion) {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrMalformed, err)
+	}
+
+	configuration.path = configPath
+
+	if configuration.Secrets != nil {
+		if err := configuration.decryptSecrets(keyFile); err != nil {
+			return nil, fmt.Errorf("%s: secrets: %w", configPath, err)
+		}
+	}
+
+	return configuration, nil
+}
+
+// namedWallet returns the index of the named wallet with the given name, or
+// -1 if there is none.
+func (c *Configuration) namedWallet(name string) int {
+	for idx, wallet := range c.NamedWallets {
+		if wallet.Name == name {
+			return idx
+		}
+	}
+
+	return -1
+}
+
+// settingChanges returns the names of the settings that differ in the given
+// configuration, and cannot be applied without a restart.
+func (c *Configuration) settingChanges(loaded *Configuration) []string {
+	var changed []string
+
+	for name, equal := range map[string]bool{
+		"rpcurl":        equalString(c.RPCURL, loaded.RPCURL),
+		"rpcuser":       equalString(c.RPCUser, loaded.RPCUser),
+		"rpcpass":       equalString(c.RPCPassword, loaded.RPCPassword),
+		"rpccookiefile": c.RPCCookieFile == loaded.RPCCookieFile,
+		"datadir":       c.DataDir == loaded.DataDir,
+		"torproxy":      c.TorProxy == loaded.TorProxy,
+		"notls":         c.NoTLS == loaded.NoTLS,
+		"prunemode":     c.PruneMode == loaded.PruneMode,
+		"port":          c.Port == loaded.Port,
+	} {
+		if !equal {
+			changed = append(changed, name)
+		}
+	}
+
+	sort.Strings(changed)
+
+	return changed
+}
+
+// checkInternalDescriptors checks that the loaded accounts of a wallet have
+// the same internal descriptor as the current accounts with the same ID.
+func checkInternalDescriptors(wallet string, current []Account, loaded []Account) error {
+	internal := make(map[string]string, len(current))
+	for _, account := range current {
+		internal[account.ID()] = *account.Internal
+	}
+
+	for _, account := range loaded {
+		previous, ok := internal[account.ID()]
+		if ok && !equalDescriptor(previous, *account.Internal) {
+			return fmt.Errorf("%w: wallet %s, account %s (%s)",
+				ErrInternalDescriptorChanged, wallet, account.ID(), *account.External)
+		}
+	}
+
+	return nil
+}
+
+// mergeAccounts merges the loaded accounts of a wallet into its current
+// ones, and returns the changes to apply. The internal descriptors of the
+// accounts must not have changed (see checkInternalDescriptors).
+func mergeAccounts(wallet string, current *[]Account, loaded []Account) AccountChanges {
+	changes := AccountChanges{Wallet: wallet}
+
+	byID := make(map[string]Account, len(loaded))
+	for _, account := range loaded {
+		byID[account.ID()] = account
+	}
+
+	known := make(map[string]bool, len(*current))
+	for idx := range *current {
+		account := &(*current)[idx]
+		id := account.ID()
+		known[id] = true
+
+		next, ok := byID[id]
+		if !ok {
+			if !account.Removed {
+				account.Removed = true
+				changes.Removed = append(changes.Removed, id)
+			}
+			continue
+		}
+
+		reimport := false
+
+		// Only the checksum or the notation may differ.
+		account.Internal = next.Internal
+
+		if !equalInt(account.Depth, next.Depth) {
+			account.Depth = next.Depth
+			reimport = true
+		}
+
+		if !equalDate(account.Birthday, next.Birthday) {
+			account.Birthday = next.Birthday
+		}
+
+		switch {
+		case next.Removed && !account.Removed:
+			changes.Removed = append(changes.Removed, id)
+		case !next.Removed && account.Removed:
+			reimport = true
+		}
+		account.Removed = next.Removed
+
+		if reimport && !account.Removed {
+			changes.Updated = append(changes.Updated, *account)
+		}
+	}
+
+	for _, account := range loaded {
+		if known[account.ID()] {
+			continue
+		}
+
+		known[account.ID()] = true
+		*current = append(*current, account)
+		if !account.Removed {
+			changes.Added = append(changes.Added, account)
+		}
+	}
+
+	return changes
+}
+
+func equalString(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+func equalInt(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+// equalDescriptor checks whether two descriptors are equal, regardless of
+// their checksum and of the notation of hardened derivation steps.
+func equalDescriptor(a string, b string) bool {
+	normalize := strings.NewReplacer("'", "h", "H", "h")
+
+	return normalize.Replace(strings.Split(a, "#")[0]) == normalize.Replace(strings.Split(b, "#")[0])
+}
+
+func equalDate(a *date, b *date) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(b.Time)
+}
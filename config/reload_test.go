@@ -0,0 +1,220 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testAccount returns an account of the BIP32 test vector key, with the
+// given script type and purpose.
+func testAccount(script string, purpose string, depth int, removed bool) Account {
+	external := script + "([3442193e/" + purpose + "h/0h/0h]" + xpub + "/0/*)"
+	internal := script + "([3442193e/" + purpose + "h/0h/0h]" + xpub + "/1/*)"
+
+	account := Account{External: &external, Internal: &internal, Removed: removed}
+	if depth > 0 {
+		account.Depth = &depth
+	}
+
+	return account
+}
+
+// writeTestConfig writes a config file with the given accounts at the given
+// path, without validating them.
+func writeTestConfig(t *testing.T, path string, accounts []Account) {
+	t.Helper()
+
+	rpcURL, rpcUser, rpcPassword := "localhost:8332", "user", "password"
+	configuration := &Configuration{
+		Version:     CurrentVersion,
+		RPCURL:      &rpcURL,
+		RPCUser:     &rpcUser,
+		RPCPassword: &rpcPassword,
+		NoTLS:       true,
+		Accounts:    accounts,
+	}
+
+	data, err := json.MarshalIndent(configuration, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFile(path, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func accountIDs(accounts []Account) []string {
+	var ids []string
+	for _, account := range accounts {
+		ids = append(ids, account.ID())
+	}
+
+	return ids
+}
+
+func TestReload(t *testing.T) {
+	wpkh := testAccount("wpkh", "84", 0, false)
+	pkh := testAccount("pkh", "44", 0, false)
+
+	// The internal descriptor of wpkh, with another notation and a checksum.
+	wpkhNotation := testAccount("wpkh", "84", 0, false)
+	internal := "wpkh([3442193e/84'/0'/0']" + xpub + "/1/*)#m0qd8ln5"
+	wpkhNotation.Internal = &internal
+
+	// The internal descriptor of wpkh, with another key origin.
+	wpkhOtherInternal := testAccount("wpkh", "84", 0, false)
+	otherInternal := "wpkh([deadbeef/84h/0h/0h]" + xpub + "/1/*)"
+	wpkhOtherInternal.Internal = &otherInternal
+
+	tests := []struct {
+		name     string
+		initial  []Account
+		reloaded []Account
+		added    []string
+		updated  []string
+		removed  []string
+		err      error
+		accounts []Account // accounts after reload, if not the reloaded ones
+	}{
+		{
+			name:     "unchanged",
+			initial:  []Account{wpkh},
+			reloaded: []Account{wpkh},
+		},
+		{
+			name:     "account added",
+			initial:  []Account{wpkh},
+			reloaded: []Account{wpkh, pkh},
+			added:    []string{pkh.ID()},
+		},
+		{
+			name:     "account deleted",
+			initial:  []Account{wpkh, pkh},
+			reloaded: []Account{wpkh},
+			removed:  []string{pkh.ID()},
+			accounts: []Account{wpkh, testAccount("pkh", "44", 0, true)},
+		},
+		{
+			name:     "account marked as removed",
+			initial:  []Account{wpkh, pkh},
+			reloaded: []Account{wpkh, testAccount("pkh", "44", 0, true)},
+			removed:  []string{pkh.ID()},
+		},
+		{
+			name:     "account restored",
+			initial:  []Account{wpkh, testAccount("pkh", "44", 0, true)},
+			reloaded: []Account{wpkh, pkh},
+			updated:  []string{pkh.ID()},
+		},
+		{
+			name:     "depth changed",
+			initial:  []Account{wpkh},
+			reloaded: []Account{testAccount("wpkh", "84", 2000, false)},
+			updated:  []string{wpkh.ID()},
+		},
+		{
+			name:     "depth of removed account changed",
+			initial:  []Account{testAccount("wpkh", "84", 0, true)},
+			reloaded: []Account{testAccount("wpkh", "84", 2000, true)},
+		},
+		{
+			name:     "internal descriptor notation changed",
+			initial:  []Account{wpkh},
+			reloaded: []Account{wpkhNotation},
+		},
+		{
+			// Rejected by validation, since both descriptors of an account
+			// must only differ by the change index.
+			name:     "internal descriptor changed",
+			initial:  []Account{wpkh},
+			reloaded: []Account{wpkhOtherInternal},
+			err:      ErrValidation,
+			accounts: []Account{wpkh},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "lss.json")
+			writeTestConfig(t, path, tt.initial)
+
+			configuration, err := Load(Overrides{Path: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writeTestConfig(t, path, tt.reloaded)
+
+			// Validation errors are wrapped with their cause as text, so
+			// they are matched by prefix.
+			changes, err := configuration.Reload()
+			if tt.err != nil {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err.Error()) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else {
+				if len(changes) != 1 || changes[0].Wallet != DefaultWallet {
+					t.Fatalf("got changes %+v", changes)
+				}
+
+				for _, c := range []struct {
+					name     string
+					actual   []string
+					expected []string
+				}{
+					{"added", accountIDs(changes[0].Added), tt.added},
+					{"updated", accountIDs(changes[0].Updated), tt.updated},
+					{"removed", changes[0].Removed, tt.removed},
+				} {
+					if !reflect.DeepEqual(c.actual, c.expected) {
+						t.Errorf("%s: got %v, want %v", c.name, c.actual, c.expected)
+					}
+				}
+			}
+
+			expected := tt.accounts
+			if expected == nil {
+				expected = tt.reloaded
+			}
+
+			if actual := configuration.Wallets()[0].AllAccounts(); !reflect.DeepEqual(actual, expected) {
+				t.Errorf("got accounts %+v, want %+v", actual, expected)
+			}
+		})
+	}
+}
+
+func TestCheckInternalDescriptors(t *testing.T) {
+	wpkh := testAccount("wpkh", "84", 0, false)
+
+	changed := testAccount("wpkh", "84", 0, false)
+	internal := "wpkh([3442193e/84h/0h/0h]" + xpub + "/2/*)"
+	changed.Internal = &internal
+
+	tests := []struct {
+		name   string
+		loaded []Account
+		err    error
+	}{
+		{name: "unchanged", loaded: []Account{wpkh}},
+		{name: "added", loaded: []Account{wpkh, testAccount("pkh", "44", 0, false)}},
+		{name: "deleted", loaded: nil},
+		{name: "internal descriptor changed", loaded: []Account{changed}, err: ErrInternalDescriptorChanged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkInternalDescriptors(DefaultWallet, []Account{wpkh}, tt.loaded)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package config
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// EnvPassphrase is the environment variable holding the passphrase of the
+// encrypted secrets. It takes precedence over the keyfile.
+const EnvPassphrase = "LSS_PASSPHRASE"
+
+// Parameters of the scrypt key derivation of new secrets. Decryption uses
+// the parameters stored along with the secrets, up to maxScryptN.
+const (
+	scryptN    = 1 << 15
+	scryptR    = 8
+	scryptP    = 1
+	maxScryptN = 1 << 20
+)
+
+// Secrets is the encrypted credentials section of the config file. The RPC
+// user and password are encrypted with XChaCha20-Poly1305, under a key
+// derived from a passphrase with scrypt.
+type Secrets struct {
+	KDF        string `json:"kdf"` // always "scrypt"
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       string `json:"salt"`       // base64
+	Nonce      string `json:"nonce"`      // base64
+	Ciphertext string `json:"ciphertext"` // base64
+}
+
+// credentials is the plaintext of the encrypted secrets.
+type credentials struct {
+	RPCUser     *string `json:"rpcuser"`
+	RPCPassword *string `json:"rpcpass"`
+}
+
+// DefaultKeyFile returns the path of the keyfile used when none is given,
+// in the .satstack folder of the home directory.
+func DefaultKeyFile() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ErrHomeNotFound, err)
+	}
+
+	return path.Join(home, ".satstack", "lss.key"), nil
+}
+
+// Passphrase returns the passphrase of the encrypted secrets, read from the
+// LSS_PASSPHRASE environment variable, or else from the given keyfile, or
+// the default one if empty.
+//
+// The keyfile can hold any passphrase, and surrounding whitespace is
+// ignored. ErrPassphraseNotFound is returned if neither is set.
+func Passphrase(keyFile string) ([]byte, error) {
+	if value := os.Getenv(EnvPassphrase); value != "" {
+		return []byte(value), nil
+	}
+
+	if keyFile == "" {
+		var err error
+		if keyFile, err = DefaultKeyFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if os.IsNotExist(err) {
+		return nil, ErrPassphraseNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	passphrase := bytes.TrimSpace(data)
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("empty keyfile: %s", keyFile)
+	}
+
+	return passphrase, nil
+}
+
+// GenerateKeyFile writes a random passphrase to the given keyfile, which is
+// only readable by the current user. It refuses to overwrite an existing
+// keyfile, which may be needed to decrypt other config files.
+func GenerateKeyFile(keyFile string) error {
+	if err := os.MkdirAll(path.Dir(keyFile), 0700); err != nil {
+		return err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(keyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Encrypt encrypts the RPC credentials of the config file with the given
+// passphrase, and saves the config file with the encrypted secrets in place
+// of the plaintext rpcuser and rpcpass.
+func (c *Configuration) Encrypt(passphrase []byte) error {
+	c.mu.Lock()
+
+	// Encrypt the values of the config file, rather than the overrides.
+	plaintext := credentials{RPCUser: c.RPCUser, RPCPassword: c.RPCPassword}
+	if c.overrides.RPCUser != nil {
+		plaintext.RPCUser = c.replaced.RPCUser
+	}
+	if c.overrides.RPCPassword != nil {
+		plaintext.RPCPassword = c.replaced.RPCPassword
+	}
+
+	if plaintext.RPCUser == nil || plaintext.RPCPassword == nil {
+		c.mu.Unlock()
+		return errors.New("no rpcuser and rpcpass to encrypt")
+	}
+
+	secrets, err := encryptCredentials(plaintext, passphrase)
+	if err != nil {
+		c.mu.Unlock()
+		return err
+	}
+
+	c.Secrets = secrets
+	c.mu.Unlock()
+
+	return c.Save()
+}
+
+// decryptSecrets sets the RPC credentials of the configuration from its
+// encrypted secrets, which cannot be used along with plaintext credentials.
+func (c *Configuration) decryptSecrets(keyFile string) error {
+	if c.RPCUser != nil || c.RPCPassword != nil {
+		return errors.New("rpcuser and rpcpass cannot be used with secrets")
+	}
+
+	passphrase, err := Passphrase(keyFile)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := decryptCredentials(c.Secrets, passphrase)
+	if err != nil {
+		return err
+	}
+
+	c.RPCUser, c.RPCPassword = plaintext.RPCUser, plaintext.RPCPassword
+
+	return nil
+}
+
+func encryptCredentials(plaintext credentials, passphrase []byte) (*Secrets, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := secretsCipher(passphrase, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Secrets{
+		KDF:        "scrypt",
+		N:          scryptN,
+		R:          scryptR,
+		P:          scryptP,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, data, nil)),
+	}, nil
+}
+
+func decryptCredentials(secrets *Secrets, passphrase []byte) (*credentials, error) {
+	if secrets.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported kdf: %q", secrets.KDF)
+	}
+
+	// Bound the cost of the key derivation, which is read from the file.
+	if secrets.N < 2 || secrets.N > maxScryptN || secrets.R < 1 || secrets.P < 1 || secrets.R*secrets.P >= 1<<30 {
+		return nil, fmt.Errorf("invalid scrypt parameters: n=%d r=%d p=%d", secrets.N, secrets.R, secrets.P)
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(secrets.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(secrets.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce: %w", err)
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(secrets.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+
+	aead, err := secretsCipher(passphrase, salt, secrets.N, secrets.R, secrets.P)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce size: %d", len(nonce))
+	}
+
+	data, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	var plaintext credentials
+	if err := json.Unmarshal(data, &plaintext); err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrDecrypt, err)
+	}
+
+	return &plaintext, nil
+}
+
+// secretsCipher returns the XChaCha20-Poly1305 cipher keyed by the given
+// passphrase.
+func secretsCipher(passphrase []byte, salt []byte, n int, r int, p int) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, n, r, p, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return chacha20poly1305.NewX(key)
+}
@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tamper flips the first bit of the given base64 value.
+func tamper(t *testing.T, value string) string {
+	t.Helper()
+
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data[0] ^= 1
+
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestCredentialsRoundTrip(t *testing.T) {
+	user, password := "user", "password"
+	passphrase := []byte("correct horse battery staple")
+
+	secrets, err := encryptCredentials(credentials{RPCUser: &user, RPCPassword: &password}, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(secrets.Ciphertext, base64.StdEncoding.EncodeToString([]byte(password))) {
+		t.Error("ciphertext contains the password")
+	}
+
+	plaintext, err := decryptCredentials(secrets, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *plaintext.RPCUser != user || *plaintext.RPCPassword != password {
+		t.Errorf("got %s %s, want %s %s", *plaintext.RPCUser, *plaintext.RPCPassword, user, password)
+	}
+}
+
+func TestDecryptCredentialsErrors(t *testing.T) {
+	user, password := "user", "password"
+	passphrase := []byte("correct horse battery staple")
+
+	secrets, err := encryptCredentials(credentials{RPCUser: &user, RPCPassword: &password}, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		mutate     func(s *Secrets)
+		passphrase string
+		err        error  // expected error, if a sentinel
+		message    string // expected error message prefix, otherwise
+	}{
+		{
+			name:       "wrong passphrase",
+			mutate:     func(s *Secrets) {},
+			passphrase: "wrong",
+			err:        ErrDecrypt,
+		},
+		{
+			name:   "tampered ciphertext",
+			mutate: func(s *Secrets) { s.Ciphertext = tamper(t, s.Ciphertext) },
+			err:    ErrDecrypt,
+		},
+		{
+			name:   "tampered nonce",
+			mutate: func(s *Secrets) { s.Nonce = tamper(t, s.Nonce) },
+			err:    ErrDecrypt,
+		},
+		{
+			name:   "tampered salt",
+			mutate: func(s *Secrets) { s.Salt = tamper(t, s.Salt) },
+			err:    ErrDecrypt,
+		},
+		{
+			name:    "short nonce",
+			mutate:  func(s *Secrets) { s.Nonce = base64.StdEncoding.EncodeToString(make([]byte, 12)) },
+			message: "invalid nonce size",
+		},
+		{
+			name:    "invalid base64",
+			mutate:  func(s *Secrets) { s.Ciphertext = "!" },
+			message: "invalid ciphertext",
+		},
+		{
+			name:    "unsupported kdf",
+			mutate:  func(s *Secrets) { s.KDF = "argon2id" },
+			message: "unsupported kdf",
+		},
+		{
+			// Deriving the key would require 1 TiB of memory.
+			name:    "scrypt n too large",
+			mutate:  func(s *Secrets) { s.N = 1 << 30 },
+			message: "invalid scrypt parameters",
+		},
+		{
+			name:    "scrypt n too small",
+			mutate:  func(s *Secrets) { s.N = 1 },
+			message: "invalid scrypt parameters",
+		},
+		{
+			name:    "scrypt r zero",
+			mutate:  func(s *Secrets) { s.R = 0 },
+			message: "invalid scrypt parameters",
+		},
+		{
+			name:    "scrypt p zero",
+			mutate:  func(s *Secrets) { s.P = 0 },
+			message: "invalid scrypt parameters",
+		},
+		{
+			name:    "scrypt r*p too large",
+			mutate:  func(s *Secrets) { s.R, s.P = 1<<15, 1<<15 },
+			message: "invalid scrypt parameters",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mutated := *secrets
+			tt.mutate(&mutated)
+
+			p := passphrase
+			if tt.passphrase != "" {
+				p = []byte(tt.passphrase)
+			}
+
+			_, err := decryptCredentials(&mutated, p)
+			switch {
+			case err == nil:
+				t.Fatal("got no error")
+			case tt.err != nil && !errors.Is(err, tt.err):
+				t.Errorf("got error %v, want %v", err, tt.err)
+			case tt.err == nil && !strings.HasPrefix(err.Error(), tt.message):
+				t.Errorf("got error %v, want %s", err, tt.message)
+			}
+		})
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	dir := t.TempDir()
+
+	keyFile := filepath.Join(dir, "lss.key")
+	if err := os.WriteFile(keyFile, []byte("  from keyfile \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	emptyKeyFile := filepath.Join(dir, "empty.key")
+	if err := os.WriteFile(emptyKeyFile, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		env      string
+		keyFile  string
+		expected string
+		err      error  // expected error, if a sentinel
+		message  string // expected error message prefix, otherwise
+	}{
+		{name: "environment", env: "from env", keyFile: keyFile, expected: "from env"},
+		{name: "keyfile", keyFile: keyFile, expected: "from keyfile"},
+		{name: "missing keyfile", keyFile: filepath.Join(dir, "missing.key"), err: ErrPassphraseNotFound},
+		{name: "empty keyfile", keyFile: emptyKeyFile, message: "empty keyfile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvPassphrase, tt.env)
+
+			passphrase, err := Passphrase(tt.keyFile)
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Errorf("got error %v, want %v", err, tt.err)
+				}
+			case tt.message != "":
+				if err == nil || !strings.HasPrefix(err.Error(), tt.message) {
+					t.Errorf("got error %v, want %s", err, tt.message)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case string(passphrase) != tt.expected:
+				t.Errorf("got %q, want %q", passphrase, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateKeyFile(t *testing.T) {
+	t.Setenv(EnvPassphrase, "")
+
+	keyFile := filepath.Join(t.TempDir(), ".satstack", "lss.key")
+	if err := GenerateKeyFile(keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFileMode(t, keyFile)
+
+	passphrase, err := Passphrase(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(passphrase) != 64 {
+		t.Errorf("got passphrase of length %d, want 64", len(passphrase))
+	}
+
+	// Existing keyfiles are never overwritten.
+	if err := GenerateKeyFile(keyFile); err == nil {
+		t.Error("overwrote existing keyfile")
+	}
+}
@@ -2,37 +2,136 @@ package config
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
+// walletNameRegexp matches the names allowed for named wallets, which are
+// used in the RPC URL and in file names.
+var walletNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 // validate checks for the validity of the JSON configuration loaded in
 // Configuration struct.
 //
 // It does not mutate the configuration values, and returns an error in case of
 // invalid configuration.
-func (c Configuration) validate() error {
+func (c *Configuration) validate() error {
 	if err := validateStringField("rpcurl", c.RPCURL); err != nil {
 		return err
 	}
 
-	if err := validateStringField("rpcuser", c.RPCUser); err != nil {
-		return err
+	// Without rpcuser and rpcpass, cookie authentication is used.
+	if c.RPCUser != nil || c.RPCPassword != nil {
+		if err := validateStringField("rpcuser", c.RPCUser); err != nil {
+			return err
+		}
+
+		if err := validateStringField("rpcpass", c.RPCPassword); err != nil {
+			return err
+		}
+
+		if c.RPCCookieFile != "" {
+			return fmt.Errorf("rpccookiefile cannot be used with rpcuser and rpcpass")
+		}
 	}
 
-	if err := validateStringField("rpcpass", c.RPCPassword); err != nil {
-		return err
+	switch c.PruneMode {
+	case "", "refuse", "clamp":
+	default:
+		return fmt.Errorf("invalid prunemode: %s", c.PruneMode)
 	}
 
-	for _, account := range c.Accounts {
-		if err := validateStringField("external", account.External); err != nil {
-			return err
+	chain := c.Chain()
+
+	for i, account := range c.Accounts {
+		if err := ValidateAccount(account, chain); err != nil {
+			return fmt.Errorf("accounts[%d]: %w", i, err)
 		}
-		if err := validateStringField("internal", account.Internal); err != nil {
-			return err
+	}
+
+	names := map[string]bool{DefaultWallet: true}
+	apiKeys := make(map[string]bool)
+	for i, wallet := range c.NamedWallets {
+		if !walletNameRegexp.MatchString(wallet.Name) {
+			return fmt.Errorf("invalid wallet name: %q", wallet.Name)
+		}
+
+		if names[wallet.Name] {
+			return fmt.Errorf("duplicate wallet name: %s", wallet.Name)
+		}
+		names[wallet.Name] = true
+
+		if wallet.APIKey != "" {
+			if apiKeys[wallet.APIKey] {
+				return fmt.Errorf("duplicate apikey for wallet: %s", wallet.Name)
+			}
+			apiKeys[wallet.APIKey] = true
+		}
+
+		for j, account := range wallet.Accounts {
+			if err := ValidateAccount(account, chain); err != nil {
+				return fmt.Errorf("wallets[%d] (%s): accounts[%d]: %w", i, wallet.Name, j, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// MaxDepth is the maximum number of addresses that can be imported per
+// descriptor, since bitcoind rejects ranges of a million addresses or more.
+const MaxDepth = 999999
+
+// ValidateAccount checks for the validity of a single account configuration,
+// without connecting to bitcoind. It is used to validate accounts added at
+// runtime, through the control API.
+//
+// The extended keys of the descriptors must be valid on the given bitcoind
+// chain, unless empty.
+func ValidateAccount(account Account, chain string) error {
+	if err := validateStringField("external", account.External); err != nil {
+		return err
+	}
+	if err := validateStringField("internal", account.Internal); err != nil {
+		return err
+	}
+
+	externalType, err := validateDescriptor(*account.External, chain, 0)
+	if err != nil {
+		return fmt.Errorf("external: %w", err)
+	}
+
+	internalType, err := validateDescriptor(*account.Internal, chain, 1)
+	if err != nil {
+		return fmt.Errorf("internal: %w", err)
+	}
+
+	if externalType != internalType {
+		return fmt.Errorf("external (%s) and internal (%s) descriptors have different script types",
+			externalType, internalType)
+	}
+
+	// Apart from the change index, both descriptors must be identical, so
+	// that change outputs are spendable by the same keys, or cosigners of a
+	// multisig account, as receive outputs.
+	if changeAgnostic(*account.External) != changeAgnostic(*account.Internal) {
+		return fmt.Errorf("external and internal descriptors differ by more than the change index (/0/* and /1/*)")
+	}
+
+	if account.Depth != nil && (*account.Depth < 1 || *account.Depth > MaxDepth) {
+		return fmt.Errorf("invalid depth: %d (must be between 1 and %d)", *account.Depth, MaxDepth)
+	}
+
+	if account.Birthday != nil {
+		// Allow for the birthday of today, in any time zone.
+		if account.Birthday.After(time.Now().Add(24 * time.Hour)) {
+			return fmt.Errorf("birthday in the future: %s", account.Birthday.Format("2006/01/02"))
 		}
 
-		if account.Birthday != nil && account.Birthday.Before(BIP0039Genesis) {
+		if account.Birthday.Before(BIP0039Genesis) {
 			log.WithFields(log.Fields{
 				"descriptor": account.External,
 				"birthday":   account.Birthday,
@@ -43,6 +142,26 @@ func (c Configuration) validate() error {
 	return nil
 }
 
+// validateDescriptor checks an output descriptor of an account, whose
+// extended keys must be derived with the given change index, and returns
+// its script type.
+func validateDescriptor(desc string, chain string, change int) (ScriptType, error) {
+	if err := verifyChecksum(desc); err != nil {
+		return "", err
+	}
+
+	scriptType, err := DescriptorScriptType(desc)
+	if err != nil {
+		return "", err
+	}
+
+	if err := validateDescriptorKeys(strings.Split(desc, "#")[0], chain, change); err != nil {
+		return "", err
+	}
+
+	return scriptType, nil
+}
+
 func validateStringField(key string, value *string) error {
 	if value == nil {
 		return fmt.Errorf("%s: %s", ErrMissingKey, key)
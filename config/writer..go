@@ -2,59 +2,200 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// WriteRescanConf writes the rescan information into a file
-// when it does not exist it saves it to the same location
+// WriteRescanConf writes the rescan information of the given wallet into a
+// file when it does not exist it saves it to the same location
 // where the lss.json is stored
-func WriteRescanConf(data *ConfigurationRescan) error {
-	paths, err := configRescanLookupPaths()
+func (c *Configuration) WriteRescanConf(wallet string, data *ConfigurationRescan) error {
+	configPath, err := c.rescanConfPath(wallet)
 	if err != nil {
 		return err
 	}
 
-	var configPath string
+	// Writing to file
+
+	data.Version = CurrentRescanVersion
+	file, err := json.MarshalIndent(*data, "", " ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(configPath, file); err != nil {
+		log.Errorf("Error saving last timestamp to file %s: %s", configPath, err)
+		return err
+	}
+
+	log.WithField("path", configPath).Info("RescanConfigFile successfully saved")
+
+	return nil
+}
+
+// rescanConfPath returns the path of the rescan checkpoint of the given
+// wallet, whether it exists or not.
+func (c *Configuration) rescanConfPath(wallet string) (string, error) {
+	paths, err := c.configRescanLookupPaths(wallet)
+	if err != nil {
+		return "", err
+	}
+
 	for _, maybePath := range paths {
 		if fileExists(maybePath) {
-			configPath = maybePath
-			break
+			return maybePath, nil
 		}
 	}
 
-	if configPath == "" {
-		// if the file does not exist, save to home dir
-		// check where the lss.json lies and take the same path
-		lssPath, err := configLookupPaths()
-		if err != nil {
-			return err
-		}
+	if c.rescanDir != "" {
+		return paths[0], nil
+	}
 
-		for index, maybePath := range lssPath {
-			if fileExists(maybePath) {
-				configPath = paths[index]
-				break
-			}
+	// if the file does not exist, save to home dir
+	// check where the lss.json lies and take the same path
+	lssPath, err := configLookupPaths()
+	if err != nil {
+		return "", err
+	}
+
+	for index, maybePath := range lssPath {
+		if fileExists(maybePath) {
+			return paths[index], nil
 		}
 	}
+
 	// This should never happen, in case we have no lss.json
 	// we should fail before
-	if configPath == "" {
+	return "", ErrConfigFileNotFound
+}
+
+// writeFile replaces the file at the given path atomically, with
+// permissions restricted to the current user.
+//
+// The data is written to a temporary file in the same directory, synced to
+// disk, and renamed over the file, so that it is never left partially
+// written if lss or the machine crashes.
+func writeFile(path string, data []byte) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	// Temporary files are created with 0600 permissions.
+	tmp, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself. Directories cannot be synced on all
+	// platforms, so this is best effort.
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+// Save writes the configuration back to the file it was loaded from. It is
+// used to persist values discovered at runtime, such as account birthdays.
+//
+// Only the values changed since the configuration was loaded or last saved
+// are patched into the file, which keeps the keys unknown to SatStack and
+// the layout of the file, as written by the user or Ledger Live. The schema
+// version of the file is kept as well: older files are only upgraded by
+// `lss config migrate`, which backs them up first.
+func (c *Configuration) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.path == "" {
 		return ErrConfigFileNotFound
 	}
 
-	// Writing to file
+	saved, err := c.savedForm()
+	if err != nil {
+		return err
+	}
+
+	file := saved
+	if current, err := os.ReadFile(c.path); err == nil && c.saved != nil {
+		if file, err = patchDocument(current, c.saved, saved); err != nil {
+			return fmt.Errorf("%s: %w", ErrMalformed, err)
+		}
+	}
 
-	file, _ := json.MarshalIndent(*data, "", " ")
-	ferr := os.WriteFile(configPath, file, 0644)
-	if ferr != nil {
-		log.Error("Error savng last timestamp to file %s: %s", configPath, ferr)
+	if err := writeFile(c.path, file); err != nil {
 		return err
 	}
 
-	log.WithField("path", configPath).Info("RescanConfigFile successfully saved")
+	c.saved = saved
+
+	log.WithField("path", c.path).Info("Config file successfully saved")
 
 	return nil
 }
+
+// savedForm returns the configuration as it is saved in the config file:
+// with the values of the config file rather than the overrides, and never
+// the decrypted secrets. The caller must hold c.mu.
+func (c *Configuration) savedForm() ([]byte, error) {
+	file, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if c.overrides == (Overrides{}) && c.Secrets == nil {
+		return file, nil
+	}
+
+	saved := &Configuration{}
+	if err := json.Unmarshal(file, saved); err != nil {
+		return nil, err
+	}
+
+	c.overrides.restore(saved, c.replaced)
+
+	if saved.Secrets != nil {
+		saved.RPCUser, saved.RPCPassword = nil, nil
+	}
+
+	return json.MarshalIndent(saved, "", "  ")
+}
+
+// SaveAs validates the configuration, and writes it to the given path, which
+// is then used by Save.
+func (c *Configuration) SaveAs(path string) error {
+	if err := c.validate(); err != nil {
+		return fmt.Errorf("%s: %w", ErrValidation, err)
+	}
+
+	c.mu.Lock()
+	c.path = path
+	c.mu.Unlock()
+
+	return c.Save()
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// checkFileMode checks that the file at the given path is only accessible
+// by the current user.
+func checkFileMode(t *testing.T, path string) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("%s: got mode %o, want 600", path, mode)
+	}
+}
+
+// checkNoTempFiles checks that no temporary file was left in the given
+// directory.
+func checkNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(matches) > 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lss.json")
+
+	for _, data := range [][]byte{[]byte(`{"version": 1}`), []byte(`{"version": 2}`)} {
+		if err := writeFile(path, data); err != nil {
+			t.Fatal(err)
+		}
+
+		written, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(written, data) {
+			t.Errorf("got %q, want %q", written, data)
+		}
+
+		checkFileMode(t, path)
+		checkNoTempFiles(t, dir)
+	}
+}
+
+func TestWriteFileError(t *testing.T) {
+	dir := t.TempDir()
+
+	// The file cannot be renamed over a directory.
+	path := filepath.Join(dir, "lss.json")
+	if err := os.Mkdir(path, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFile(path, []byte(`{}`)); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	checkNoTempFiles(t, dir)
+
+	if err := writeFile(filepath.Join(dir, "missing", "lss.json"), []byte(`{}`)); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestRescanConfRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	configuration := &Configuration{rescanDir: dir}
+
+	for _, wallet := range []string{DefaultWallet, "alice"} {
+		checkpoint := &ConfigurationRescan{
+			LastSyncTime:    "2021-06-01T00:00:00Z",
+			TimeStamp:       "1622505600",
+			LastBlock:       685000,
+			SatstackVersion: "v0.20.0",
+		}
+
+		if err := configuration.WriteRescanConf(wallet, checkpoint); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := configuration.LoadRescanConf(wallet)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkpoint.Version = CurrentRescanVersion
+		if *loaded != *checkpoint {
+			t.Errorf("%s: got %+v, want %+v", wallet, *loaded, *checkpoint)
+		}
+
+		path, err := configuration.rescanConfPath(wallet)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkFileMode(t, path)
+	}
+
+	checkNoTempFiles(t, dir)
+}
+
+func TestLoadRescanConfMissing(t *testing.T) {
+	configuration := &Configuration{rescanDir: t.TempDir()}
+
+	if _, err := configuration.LoadRescanConf(DefaultWallet); err != ErrConfigFileNotFound {
+		t.Errorf("got %v, want %v", err, ErrConfigFileNotFound)
+	}
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lss.json")
+
+	rpcURL, rpcUser, rpcPassword := "localhost:8332", "user", "password"
+	external := "wpkh([3442193e/84h/0h/0h]" + xpub + "/0/*)"
+	internal := "wpkh([3442193e/84h/0h/0h]" + xpub + "/1/*)"
+	depth := 2000
+
+	configuration := &Configuration{
+		Version:     CurrentVersion,
+		RPCURL:      &rpcURL,
+		RPCUser:     &rpcUser,
+		RPCPassword: &rpcPassword,
+		NoTLS:       true,
+		Accounts: []Account{
+			{External: &external, Internal: &internal, Depth: &depth},
+		},
+	}
+
+	if err := configuration.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFileMode(t, path)
+	checkNoTempFiles(t, dir)
+
+	loaded, err := Load(Overrides{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Version != CurrentVersion {
+		t.Errorf("version: got %d, want %d", loaded.Version, CurrentVersion)
+	}
+
+	// Rescan checkpoints are kept next to a config file given by path.
+	if loaded.rescanDir != dir {
+		t.Errorf("rescan dir: got %s, want %s", loaded.rescanDir, dir)
+	}
+
+	if *loaded.RPCURL != rpcURL || *loaded.RPCUser != rpcUser || *loaded.RPCPassword != rpcPassword {
+		t.Errorf("rpc: got %s %s %s", *loaded.RPCURL, *loaded.RPCUser, *loaded.RPCPassword)
+	}
+
+	accounts := loaded.Wallets()[0].ActiveAccounts()
+	if len(accounts) != 1 || accounts[0].ID() != configuration.Accounts[0].ID() || *accounts[0].Depth != depth {
+		t.Fatalf("got accounts %+v", accounts)
+	}
+
+	// Values changed at runtime are saved in place.
+	loaded.Wallets()[0].UpdateAccount(accounts[0].ID(), func(account *Account) {
+		account.Removed = true
+	})
+
+	if err := loaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkFileMode(t, path)
+	checkNoTempFiles(t, dir)
+
+	reloaded, err := Load(Overrides{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if accounts := reloaded.Wallets()[0].AllAccounts(); len(accounts) != 1 || !accounts[0].Removed {
+		t.Errorf("got accounts %+v", accounts)
+	}
+}
+
+func TestSaveKeepsOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lss.json")
+	data := []byte(`{
+    "version": 1,
+    "rpcurl": "localhost:8332",
+    "rpcuser": "user",
+    "rpcpass": "password",
+    "torproxy": "",
+    "notls": true,
+    "accounts": [],
+    "comment": "kept"
+}
+`)
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	override := "other"
+	configuration, err := Load(Overrides{Path: path, RPCPassword: &override})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *configuration.RPCPassword != override {
+		t.Errorf("got rpcpass %s, want %s", *configuration.RPCPassword, override)
+	}
+
+	if err := configuration.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(saved, data) {
+		t.Errorf("got %s, want %s", saved, data)
+	}
+}
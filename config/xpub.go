@@ -0,0 +1,155 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// Scheme is the derivation scheme of an account, named after the derivation
+// modes of Ledger Live.
+type Scheme string
+
+const (
+	// SchemeLegacy is the BIP44 scheme, for P2PKH outputs.
+	SchemeLegacy Scheme = "legacy"
+
+	// SchemeSegwit is the BIP49 scheme, for P2WPKH nested in P2SH outputs.
+	SchemeSegwit Scheme = "segwit"
+
+	// SchemeNativeSegwit is the BIP84 scheme, for P2WPKH outputs.
+	SchemeNativeSegwit Scheme = "native_segwit"
+
+	// SchemeTaproot is the BIP86 scheme, for P2TR key path outputs.
+	SchemeTaproot Scheme = "taproot"
+)
+
+// purpose returns the BIP43 purpose of the derivation scheme.
+func (s Scheme) purpose() (uint32, error) {
+	switch s {
+	case SchemeLegacy:
+		return 44, nil
+	case SchemeSegwit:
+		return 49, nil
+	case SchemeNativeSegwit:
+		return 84, nil
+	case SchemeTaproot:
+		return 86, nil
+	default:
+		return 0, fmt.Errorf("unknown scheme: %q", s)
+	}
+}
+
+// descriptor wraps the given key expression in the script expression of the
+// derivation scheme.
+func (s Scheme) descriptor(key string) string {
+	switch s {
+	case SchemeLegacy:
+		return fmt.Sprintf("pkh(%s)", key)
+	case SchemeSegwit:
+		return fmt.Sprintf("sh(wpkh(%s))", key)
+	case SchemeTaproot:
+		return fmt.Sprintf("tr(%s)", key)
+	default:
+		return fmt.Sprintf("wpkh(%s)", key)
+	}
+}
+
+// extendedKeyVersion describes the network and the derivation scheme implied
+// by the version bytes of an extended public key, as registered in SLIP-132.
+type extendedKeyVersion struct {
+	chain  string // bitcoind chain name
+	scheme Scheme // empty if the version doesn't imply a scheme
+}
+
+var extendedKeyVersions = map[string]extendedKeyVersion{
+	"0488b21e": {chain: "main"},                             // xpub
+	"049d7cb2": {chain: "main", scheme: SchemeSegwit},       // ypub
+	"04b24746": {chain: "main", scheme: SchemeNativeSegwit}, // zpub
+	"043587cf": {chain: "test"},                             // tpub
+	"044a5262": {chain: "test", scheme: SchemeSegwit},       // upub
+	"045f1cf6": {chain: "test", scheme: SchemeNativeSegwit}, // vpub
+}
+
+// AccountFromXPub returns the account of the given BIP32 extended public key,
+// along with the bitcoind chain name of its network ("main" or "test").
+//
+// The extended key must be an account-level key (depth 3), serialized with
+// any of the xpub/ypub/zpub/tpub/upub/vpub versions. The derivation scheme is
+// inferred from ypub/zpub/upub/vpub versions; it must be given for xpub and
+// tpub keys, which are used by all schemes, and ErrUnknownScheme is returned
+// otherwise. The fingerprint of the master key is required, for the key origin
+// of the descriptors.
+func AccountFromXPub(xpub string, scheme Scheme, fingerprint string) (*Account, string, error) {
+	key, err := hdkeychain.NewKeyFromString(xpub)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid extended key: %w", err)
+	}
+
+	if key.IsPrivate() {
+		return nil, "", fmt.Errorf("refusing to use an extended private key")
+	}
+
+	version, ok := extendedKeyVersions[hex.EncodeToString(key.Version())]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown extended key version: %x", key.Version())
+	}
+
+	switch {
+	case scheme == "" && version.scheme == "":
+		return nil, "", fmt.Errorf("%w of %s keys, please specify it", ErrUnknownScheme, xpub[:4])
+	case scheme == "":
+		scheme = version.scheme
+	case version.scheme != "" && scheme != version.scheme:
+		return nil, "", fmt.Errorf("scheme %s does not match %s key", scheme, xpub[:4])
+	}
+
+	purpose, err := scheme.purpose()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if key.Depth() != 3 || key.ChildIndex() < hdkeychain.HardenedKeyStart {
+		return nil, "", fmt.Errorf("not an account extended key (depth %d)", key.Depth())
+	}
+
+	// The fingerprint is part of the key origin, used by signers to find the
+	// key, and of the descriptors, from which the account ID is derived.
+	if fingerprint == "" {
+		return nil, "", errors.New("missing master key fingerprint")
+	}
+
+	if b, err := hex.DecodeString(fingerprint); err != nil || len(b) != 4 {
+		return nil, "", fmt.Errorf("invalid fingerprint: %s", fingerprint)
+	}
+
+	// bitcoind only accepts the xpub and tpub versions in descriptors.
+	params, coinType := &chaincfg.MainNetParams, uint32(0)
+	if version.chain == "test" {
+		params, coinType = &chaincfg.TestNet3Params, uint32(1)
+	}
+
+	key, err = key.CloneWithVersion(params.HDPublicKeyID[:])
+	if err != nil {
+		return nil, "", err
+	}
+
+	origin := fmt.Sprintf("[%s/%d'/%d'/%d']", fingerprint, purpose, coinType,
+		key.ChildIndex()-hdkeychain.HardenedKeyStart)
+
+	var descs []string
+	for _, change := range []int{0, 1} {
+		desc, err := WithChecksum(scheme.descriptor(
+			fmt.Sprintf("%s%s/%d/*", origin, key.String(), change)))
+		if err != nil {
+			return nil, "", err
+		}
+
+		descs = append(descs, desc)
+	}
+
+	return &Account{External: &descs[0], Internal: &descs[1]}, version.chain, nil
+}
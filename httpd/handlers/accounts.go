@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ledgerhq/satstack/config"
+	"github.com/ledgerhq/satstack/httpd/svc"
+	log "github.com/sirupsen/logrus"
+)
+
+func ListAccounts(s svc.AccountsService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accounts, err := s.ListAccounts()
+		if err != nil {
+			log.WithField("error", err).Error("Failed to list accounts")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, accounts)
+	}
+}
+
+// AddAccounts validates the accounts in the request, persists them in the
+// configuration and starts importing them. The response contains the ID of
+// the import job, which can be queried with GetJob.
+func AddAccounts(s svc.AccountsService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var request struct {
+			Accounts []config.Account `json:"accounts" binding:"required"`
+		}
+
+		if err := ctx.BindJSON(&request); err != nil {
+			log.Error("Failed to bind JSON request")
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		jobID, err := s.AddAccounts(request.Accounts)
+		if err != nil {
+			log.WithField("error", err).Error("Failed to add accounts")
+			ctx.JSON(accountErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, gin.H{"job_id": jobID})
+	}
+}
+
+// UpdateAccount updates the depth and/or birthday of an account. If the depth
+// is changed, or the birthday moved earlier, the response contains the ID of
+// the resulting import or rescan job.
+func UpdateAccount(s svc.AccountsService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var request config.Account
+
+		if err := ctx.BindJSON(&request); err != nil {
+			log.Error("Failed to bind JSON request")
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		jobID, err := s.UpdateAccount(ctx.Param("id"), request)
+		if err != nil {
+			log.WithField("error", err).Error("Failed to update account")
+			ctx.JSON(accountErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		if jobID == "" {
+			ctx.JSON(http.StatusOK, gin.H{"Status": "OK"})
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, gin.H{"job_id": jobID})
+	}
+}
+
+func RemoveAccount(s svc.AccountsService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := s.RemoveAccount(ctx.Param("id")); err != nil {
+			log.WithField("error", err).Error("Failed to remove account")
+			ctx.JSON(accountErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"Status": "OK"})
+	}
+}
+
+// accountErrorStatus returns the HTTP status code corresponding to an error
+// returned by the accounts service.
+func accountErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, svc.ErrAccountNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, svc.ErrAccountExists):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/ledgerhq/satstack/httpd/svc"
 	"github.com/ledgerhq/satstack/types"
@@ -35,3 +37,79 @@ func GetBlock(s svc.BlocksService) gin.HandlerFunc {
 		}
 	}
 }
+
+// GetBlockRange gets the headers of the blocks between the heights given by
+// the "from" and "to" query parameters, both inclusive.
+//
+// Example:
+//   - ?from=626550&to=626553 -> get headers of 4 blocks
+//
+// The response is a list of blocks without transactions, in ascending order
+// of height.
+func GetBlockRange(s svc.BlocksService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		from, to, ok := parseHeightRange(ctx)
+		if !ok {
+			return
+		}
+
+		blocks, err := s.GetBlockRange(from, to)
+		if err != nil {
+			ctx.String(blockRangeStatus(err), "text/plain", []byte(err.Error()))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, blocks)
+	}
+}
+
+// GetBlockHeaders gets the raw 80-byte headers of the blocks between the
+// heights given by the "from" and "to" query parameters, both inclusive.
+//
+// The response is a list of hex-encoded headers, in ascending order of
+// height, that clients can use to independently validate the chain.
+func GetBlockHeaders(s svc.BlocksService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		from, to, ok := parseHeightRange(ctx)
+		if !ok {
+			return
+		}
+
+		headers, err := s.GetBlockHeaders(from, to)
+		if err != nil {
+			ctx.String(blockRangeStatus(err), "text/plain", []byte(err.Error()))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, headers)
+	}
+}
+
+// blockRangeStatus returns the HTTP status code of an error returned by a
+// service method taking a range of block heights.
+func blockRangeStatus(err error) int {
+	if errors.Is(err, svc.ErrInvalidBlockRange) {
+		return http.StatusBadRequest
+	}
+
+	return http.StatusNotFound
+}
+
+// parseHeightRange parses the "from" and "to" query parameters as block
+// heights. In case of invalid input, it writes a Bad Request response and
+// returns false.
+func parseHeightRange(ctx *gin.Context) (int64, int64, bool) {
+	from, err := strconv.ParseInt(ctx.Query("from"), 10, 64)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "text/plain", []byte("invalid 'from' height"))
+		return 0, 0, false
+	}
+
+	to, err := strconv.ParseInt(ctx.Query("to"), 10, 64)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "text/plain", []byte("invalid 'to' height"))
+		return 0, 0, false
+	}
+
+	return from, to, true
+}
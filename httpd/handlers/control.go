@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/ledgerhq/satstack/bus"
 	"github.com/ledgerhq/satstack/config"
 	"github.com/ledgerhq/satstack/httpd/svc"
 	log "github.com/sirupsen/logrus"
@@ -22,9 +25,9 @@ func ImportAccounts(s svc.ControlService) gin.HandlerFunc {
 			return
 		}
 
-		s.ImportAccounts(request.Accounts)
+		jobID := s.ImportAccounts(request.Accounts)
 
-		ctx.JSON(http.StatusOK, gin.H{"Status": "OK"})
+		ctx.JSON(http.StatusOK, gin.H{"Status": "OK", "job_id": jobID})
 	}
 }
 
@@ -52,3 +55,94 @@ func HasDescriptor(s svc.ControlService) gin.HandlerFunc {
 		})
 	}
 }
+
+func ListJobs(s svc.ControlService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, s.ListJobs())
+	}
+}
+
+func GetJob(s svc.ControlService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		job, err := s.GetJob(ctx.Param("id"))
+		if err != nil {
+			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, job)
+	}
+}
+
+// CancelJob cancels a running job. Only imports and rescans can be cancelled,
+// by aborting the wallet rescan in bitcoind.
+func CancelJob(s svc.ControlService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if err := s.CancelJob(ctx.Param("id")); err != nil {
+			log.WithField("error", err).Error("Failed to cancel job")
+
+			status := http.StatusConflict
+			if errors.Is(err, bus.ErrJobNotFound) {
+				status = http.StatusNotFound
+			}
+
+			ctx.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"Status": "OK"})
+	}
+}
+
+// Rescan starts a rescan of the wallet in a background job. The response
+// contains the ID of the job, and the progress of the rescan is reported by
+// the status endpoint.
+//
+// Example request body:
+//
+//	{"from_date": "2021/06/01", "account": "2f0bc4aa1d6e5b1f"}
+func Rescan(s svc.ControlService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var request struct {
+			FromHeight *int64  `json:"from_height"`
+			ToHeight   *int64  `json:"to_height"`
+			FromDate   *string `json:"from_date"` // YYYY/MM/DD
+			Account    string  `json:"account"`   // (?) ID of the account to rescan
+		}
+
+		if err := ctx.BindJSON(&request); err != nil {
+			log.Error("Failed to bind JSON request")
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var fromDate *time.Time
+		if request.FromDate != nil {
+			t, err := time.Parse("2006/01/02", *request.FromDate)
+			if err != nil {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			fromDate = &t
+		}
+
+		jobID, err := s.Rescan(request.FromHeight, request.ToHeight, fromDate, request.Account)
+		if err != nil {
+			log.WithField("error", err).Error("Failed to start rescan")
+
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, bus.ErrScanInProgress):
+				status = http.StatusConflict
+			case errors.Is(err, svc.ErrAccountNotFound):
+				status = http.StatusNotFound
+			}
+
+			ctx.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, gin.H{"job_id": jobID})
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ledgerhq/satstack/bus"
+	"github.com/ledgerhq/satstack/httpd/svc"
+)
+
+// GetBlockFilter gets the BIP-0158 compact filter of a block by height or
+// hash, along with its BIP-0157 filter header.
+func GetBlockFilter(s svc.FiltersService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		blockRef := ctx.Param("block")
+
+		filter, err := s.GetBlockFilter(blockRef)
+		if err != nil {
+			ctx.String(filterErrorStatus(err), "text/plain", []byte(err.Error()))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, filter)
+	}
+}
+
+// GetBlockFilters gets the compact filters of the blocks between the heights
+// given by the "from" and "to" query parameters, both inclusive.
+func GetBlockFilters(s svc.FiltersService) gin.HandlerFunc {
+	return getBlockFilters(s, true)
+}
+
+// GetBlockFilterHeaders gets the filter header chain between the heights
+// given by the "from" and "to" query parameters, both inclusive.
+func GetBlockFilterHeaders(s svc.FiltersService) gin.HandlerFunc {
+	return getBlockFilters(s, false)
+}
+
+func getBlockFilters(s svc.FiltersService, withFilters bool) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		from, to, ok := parseHeightRange(ctx)
+		if !ok {
+			return
+		}
+
+		filters, err := s.GetBlockFilters(from, to, withFilters)
+		if err != nil {
+			ctx.String(filterErrorStatus(err), "text/plain", []byte(err.Error()))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, filters)
+	}
+}
+
+// filterErrorStatus returns the HTTP status code corresponding to an error
+// encountered while fetching compact block filters.
+func filterErrorStatus(err error) int {
+	if errors.Is(err, bus.ErrBlockFilterDisabled) {
+		return http.StatusNotImplemented
+	}
+
+	if errors.Is(err, svc.ErrInvalidBlockRange) {
+		return http.StatusBadRequest
+	}
+
+	return http.StatusNotFound
+}
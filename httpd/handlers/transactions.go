@@ -29,6 +29,22 @@ func GetTransactionHex(s svc.TransactionsService) gin.HandlerFunc {
 	}
 }
 
+// GetMerkleProof is a gin handler (factory) to query the merkle inclusion
+// proof of a wallet transaction by hash parameter.
+func GetMerkleProof(s svc.TransactionsService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		txHash := ctx.Param("hash")
+
+		proof, err := s.GetMerkleProof(txHash)
+		if err != nil {
+			ctx.String(http.StatusNotFound, "text/plain", []byte(err.Error()))
+			return
+		}
+
+		ctx.JSON(http.StatusOK, proof)
+	}
+}
+
 func SendTransaction(s svc.TransactionsService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var request struct {
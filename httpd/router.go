@@ -17,6 +17,17 @@ func GetRouter(s *svc.Service) *gin.Engine {
 	{
 		controlRouter.GET("descriptors/import", handlers.ImportAccounts(s))
 		controlRouter.POST("descriptors/has", handlers.HasDescriptor(s))
+
+		controlRouter.GET("accounts", handlers.ListAccounts(s))
+		controlRouter.POST("accounts", handlers.AddAccounts(s))
+		controlRouter.PATCH("accounts/:id", handlers.UpdateAccount(s))
+		controlRouter.DELETE("accounts/:id", handlers.RemoveAccount(s))
+
+		controlRouter.GET("jobs", handlers.ListJobs(s))
+		controlRouter.GET("jobs/:id", handlers.GetJob(s))
+		controlRouter.POST("jobs/:id/cancel", handlers.CancelJob(s))
+
+		controlRouter.POST("rescan", handlers.Rescan(s))
 	}
 
 	// We support both Ledger Blockchain Explorer v2 and v3. The version here
@@ -35,12 +46,18 @@ func GetRouter(s *svc.Service) *gin.Engine {
 
 	blocksRouter := currencyRouter.Group("/blocks")
 	{
+		blocksRouter.GET("", handlers.GetBlockRange(s))
+		blocksRouter.GET("headers", handlers.GetBlockHeaders(s))
 		blocksRouter.GET(":block", handlers.GetBlock(s))
+		blocksRouter.GET(":block/filter", handlers.GetBlockFilter(s))
+		blocksRouter.GET("filters", handlers.GetBlockFilters(s))
+		blocksRouter.GET("filters/headers", handlers.GetBlockFilterHeaders(s))
 	}
 
 	transactionsRouter := currencyRouter.Group("/transactions")
 	{
 		transactionsRouter.GET(":hash/hex", handlers.GetTransactionHex(s))
+		transactionsRouter.GET(":hash/proof", handlers.GetMerkleProof(s))
 		transactionsRouter.POST("send", handlers.SendTransaction(s))
 	}
 
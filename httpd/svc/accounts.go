@@ -0,0 +1,327 @@
+package svc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/ledgerhq/satstack/bus"
+	"github.com/ledgerhq/satstack/config"
+	"github.com/ledgerhq/satstack/types"
+)
+
+// ListAccounts is a service method to list the configured accounts, along
+// with their import status.
+func (s *Service) ListAccounts() ([]types.Account, error) {
+	var result []types.Account
+	for _, account := range s.Config.AllAccounts() {
+		info := types.Account{
+			ID:        account.ID(),
+			External:  *account.External,
+			Internal:  *account.Internal,
+			Threshold: config.DescriptorThreshold(*account.External),
+			Keys:      []types.KeyOrigin{},
+			Depth:     bus.AccountDepth(account),
+			Removed:   account.Removed,
+		}
+
+		if scriptType, err := config.DescriptorScriptType(*account.External); err == nil {
+			info.ScriptType = string(scriptType)
+		}
+
+		for _, origin := range config.DescriptorKeyOrigins(*account.External) {
+			info.Keys = append(info.Keys, types.KeyOrigin{
+				Fingerprint: origin.Fingerprint,
+				Path:        origin.Path,
+				XPub:        origin.XPub,
+			})
+		}
+
+		if account.Birthday != nil {
+			info.Birthday = account.Birthday.Format("2006/01/02")
+		}
+
+		switch {
+		case account.Removed:
+			info.Status = "removed"
+		case s.Bus.Jobs.Running(bus.JobImport, info.ID):
+			info.Status = "importing"
+		default:
+			imported, err := s.HasDescriptor(*account.External)
+			if err != nil {
+				return nil, err
+			}
+
+			if imported {
+				info.Status = "imported"
+			} else {
+				info.Status = "not-imported"
+			}
+		}
+
+		result = append(result, info)
+	}
+
+	return result, nil
+}
+
+// AddAccounts is a service method to validate accounts, persist them in the
+// configuration, and import them in the background. Removed accounts can be
+// added again, which restores them.
+//
+// It returns the ID of the import job, which can be used to query its state.
+func (s *Service) AddAccounts(accounts []config.Account) (string, error) {
+	client, err := s.Bus.ClientFactory()
+	if err != nil {
+		return "", err
+	}
+
+	defer client.Shutdown()
+
+	for i, account := range accounts {
+		if err := config.ValidateAccount(account, s.Config.Chain()); err != nil {
+			return "", fmt.Errorf("accounts[%d]: %w", i, err)
+		}
+
+		for _, desc := range []string{*account.External, *account.Internal} {
+			if _, err := bus.GetCanonicalDescriptor(client, strings.Split(desc, "#")[0]); err != nil {
+				return "", fmt.Errorf("%s: %w", bus.ErrInvalidDescriptor, err)
+			}
+		}
+	}
+
+	if err := s.Config.AddAccounts(accounts); err != nil {
+		return "", err
+	}
+
+	var ids []string
+	for _, account := range accounts {
+		// Restored accounts are no longer filtered out.
+		s.removedAddresses.Delete(account.ID())
+		ids = append(ids, account.ID())
+	}
+
+	if err := s.Config.Save(); err != nil {
+		return "", err
+	}
+
+	return s.startImport(ids, accounts), nil
+}
+
+// UpdateAccount is a service method to update the depth and/or the birthday
+// of an account. The updated account is validated before being saved.
+//
+// If the depth is changed, the account is imported again in the background.
+// Otherwise, if the birthday is moved earlier, the account is rescanned in
+// the background from its new birthday. In both cases, the ID of the job is
+// returned.
+func (s *Service) UpdateAccount(id string, update config.Account) (string, error) {
+	var (
+		previous, updated config.Account
+		depthChanged      bool
+		err               error
+	)
+
+	chain := s.Config.Chain()
+	found := s.Config.UpdateAccount(id, func(account *config.Account) {
+		next := *account
+
+		if update.Depth != nil {
+			depthChanged = *update.Depth != bus.AccountDepth(*account)
+			next.Depth = update.Depth
+		}
+
+		if update.Birthday != nil {
+			next.Birthday = update.Birthday
+		}
+
+		if err = config.ValidateAccount(next, chain); err != nil {
+			return
+		}
+
+		previous, updated = *account, next
+		*account = next
+	})
+
+	if !found {
+		return "", fmt.Errorf("%w: %s", ErrAccountNotFound, id)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.Config.Save(); err != nil {
+		return "", err
+	}
+
+	switch {
+	case updated.Removed:
+		return "", nil
+	case depthChanged:
+		// The descriptors are imported with the birthday of the account as
+		// timestamp, so the import also covers an earlier birthday.
+		return s.startImport([]string{id}, []config.Account{updated}), nil
+	case birthday(updated).Before(birthday(previous)):
+		return s.startBirthdayRescan(updated), nil
+	default:
+		return "", nil
+	}
+}
+
+// RemoveAccount is a service method to mark an account as removed.
+//
+// Descriptors cannot be removed from a bitcoind wallet, so the transactions
+// of a removed account are instead filtered out of explorer responses.
+func (s *Service) RemoveAccount(id string) error {
+	found := s.Config.UpdateAccount(id, func(account *config.Account) {
+		account.Removed = true
+	})
+
+	if !found {
+		return fmt.Errorf("%w: %s", ErrAccountNotFound, id)
+	}
+
+	s.removedAddresses.Delete(id)
+
+	return s.Config.Save()
+}
+
+// ApplyAccountChanges imports the accounts added or updated in the config
+// file, once it was reloaded, in the background. Like accounts added
+// through the control API, the import waits for the scan in progress, if
+// any, and stops waiting on shutdown or if the job is cancelled.
+//
+// It returns the ID of the import job, or an empty string if there is
+// nothing to import.
+func (s *Service) ApplyAccountChanges(changes config.AccountChanges) string {
+	// Drop the cached addresses of changed accounts, which may be outdated.
+	for _, id := range changes.Removed {
+		s.removedAddresses.Delete(id)
+	}
+
+	accounts := append(append([]config.Account(nil), changes.Added...), changes.Updated...)
+	if len(accounts) == 0 {
+		return ""
+	}
+
+	var ids []string
+	for _, account := range accounts {
+		s.removedAddresses.Delete(account.ID())
+		ids = append(ids, account.ID())
+	}
+
+	return s.Bus.Jobs.StartScan(bus.JobImport, ids, bus.StateImporting, "config file reloaded", func() error {
+		return s.Bus.ImportAccounts(accounts)
+	})
+}
+
+// excludeRemovedAddresses returns the given addresses, minus those belonging
+// to removed accounts.
+func (s *Service) excludeRemovedAddresses(addresses []string) ([]string, error) {
+	if s.Config == nil {
+		return addresses, nil
+	}
+
+	removed := make(map[string]bool)
+	for _, account := range s.Config.AllAccounts() {
+		if !account.Removed {
+			continue
+		}
+
+		accountAddresses, err := s.accountAddresses(account)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, address := range accountAddresses {
+			removed[address] = true
+		}
+	}
+
+	if len(removed) == 0 {
+		return addresses, nil
+	}
+
+	var result []string
+	for _, address := range addresses {
+		if !removed[address] {
+			result = append(result, address)
+		}
+	}
+
+	return result, nil
+}
+
+// accountAddresses returns the imported addresses of the given account, on
+// both external and internal chains. Results are cached by account ID.
+func (s *Service) accountAddresses(account config.Account) ([]string, error) {
+	if cached, found := s.removedAddresses.Load(account.ID()); found {
+		return cached.([]string), nil
+	}
+
+	client, err := s.Bus.ClientFactory()
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.Shutdown()
+
+	depth := bus.AccountDepth(account)
+
+	var result []string
+	for _, desc := range []string{*account.External, *account.Internal} {
+		canonicalDesc, err := bus.GetCanonicalDescriptor(client, strings.Split(desc, "#")[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", bus.ErrInvalidDescriptor, err)
+		}
+
+		addresses, err := client.DeriveAddresses(*canonicalDesc,
+			&btcjson.DescriptorRange{Value: []int{0, depth}})
+		if err != nil {
+			return nil, fmt.Errorf("%s (%s): %w", bus.ErrDeriveAddress, *canonicalDesc, err)
+		}
+
+		result = append(result, *addresses...)
+	}
+
+	s.removedAddresses.Store(account.ID(), result)
+
+	return result, nil
+}
+
+// startImport imports the given accounts in a background job, and returns the
+// ID of the job. The job waits for the scan in progress, if any, such as the
+// startup scan, so that accounts persisted in the configuration are always
+// imported.
+func (s *Service) startImport(ids []string, accounts []config.Account) string {
+	return s.Bus.Jobs.StartScan(bus.JobImport, ids, bus.StateImporting, "accounts added", func() error {
+		return s.Bus.ImportAccounts(accounts)
+	})
+}
+
+// startBirthdayRescan rescans the given account from its birthday in a
+// background job, and returns the ID of the job. Like imports, the job waits
+// for the scan in progress, if any.
+func (s *Service) startBirthdayRescan(account config.Account) string {
+	return s.Bus.Jobs.StartScan(bus.JobRescan, []string{account.ID()}, bus.StateRescanning,
+		"account birthday moved earlier", func() error {
+			from, err := s.Bus.GetHeightByTime(birthday(account))
+			if err != nil {
+				return err
+			}
+
+			return s.Bus.RescanAccount(account, from)
+		})
+}
+
+// birthday returns the birthday of the given account, or the BIP39 genesis
+// date if it has none, which is where scans of such accounts start.
+func birthday(account config.Account) time.Time {
+	if account.Birthday == nil {
+		return config.BIP0039Genesis
+	}
+
+	return account.Birthday.Time
+}
@@ -16,6 +16,12 @@ func (s *Service) GetAddresses(addresses []string, blockHash *string, blockHeigh
 	s.Bus.NewCache()
 	defer s.Bus.FlushCache()
 
+	// Transactions of removed accounts must not be reported.
+	addresses, err := s.excludeRemovedAddresses(addresses)
+	if err != nil {
+		return types.Addresses{}, err
+	}
+
 	blockchainInfo, err := s.Bus.GetBlockChainInfo()
 	if err != nil {
 		return types.Addresses{}, err
@@ -50,3 +50,84 @@ func (s *Service) getBlockHashByReference(ref string) (*chainhash.Hash, error) {
 
 	}
 }
+
+// maxBlockRange indicates the maximum number of block headers that can be
+// requested in a single range query.
+const maxBlockRange = 2016
+
+// GetBlockRange is a service method to get the headers of the blocks between
+// two heights, both inclusive, in ascending order of height.
+func (s *Service) GetBlockRange(from int64, to int64) ([]*types.Block, error) {
+	if err := s.checkBlockRange(from, to); err != nil {
+		return nil, err
+	}
+
+	headers := make([]*types.Block, 0, to-from+1)
+	for height := from; height <= to; height++ {
+		hash, err := s.Bus.GetBlockHash(height)
+		if err != nil {
+			return nil, err
+		}
+
+		header, err := s.Bus.GetBlockHeader(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		headers = append(headers, header)
+	}
+
+	return headers, nil
+}
+
+// GetBlockHeaders is a service method to get the raw headers of the blocks
+// between two heights, both inclusive, in ascending order of height.
+func (s *Service) GetBlockHeaders(from int64, to int64) ([]types.BlockHeader, error) {
+	if err := s.checkBlockRange(from, to); err != nil {
+		return nil, err
+	}
+
+	headers := make([]types.BlockHeader, 0, to-from+1)
+	for height := from; height <= to; height++ {
+		hash, err := s.Bus.GetBlockHash(height)
+		if err != nil {
+			return nil, err
+		}
+
+		headerHex, err := s.Bus.GetRawBlockHeader(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		headers = append(headers, types.BlockHeader{
+			Height: height,
+			Hash:   hash.String(),
+			Hex:    headerHex,
+		})
+	}
+
+	return headers, nil
+}
+
+// checkBlockRange returns an error if the given range of heights is invalid,
+// too large, or goes beyond the tip of the chain.
+func (s *Service) checkBlockRange(from int64, to int64) error {
+	if from < 0 || to < from {
+		return fmt.Errorf("%w: [%d, %d]", ErrInvalidBlockRange, from, to)
+	}
+
+	if to-from+1 > maxBlockRange {
+		return fmt.Errorf("%w: too large, max %d blocks", ErrInvalidBlockRange, maxBlockRange)
+	}
+
+	blockCount, err := s.Bus.GetBlockCount()
+	if err != nil {
+		return err
+	}
+
+	if to > blockCount {
+		return fmt.Errorf("%w: exceeds chain tip at height %d", ErrInvalidBlockRange, blockCount)
+	}
+
+	return nil
+}
@@ -5,17 +5,34 @@ import (
 
 	"github.com/ledgerhq/satstack/bus"
 	"github.com/ledgerhq/satstack/config"
-	log "github.com/sirupsen/logrus"
 )
 
-func (s *Service) ImportAccounts(accounts []config.Account) {
-	go func() {
-		if err := s.Bus.ImportAccounts(accounts); err != nil {
-			log.WithFields(log.Fields{
-				"error": err,
-			}).Error("Failed to import accounts")
+// ImportAccounts imports the given accounts in a background job, and returns
+// the ID of the job.
+func (s *Service) ImportAccounts(accounts []config.Account) string {
+	ids := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		if account.External != nil {
+			ids = append(ids, account.ID())
 		}
-	}()
+	}
+
+	return s.startImport(ids, accounts)
+}
+
+// ListJobs returns the background jobs, in the order they were started.
+func (s *Service) ListJobs() []bus.Job {
+	return s.Bus.Jobs.List()
+}
+
+// GetJob returns the background job with the given ID.
+func (s *Service) GetJob(id string) (*bus.Job, error) {
+	return s.Bus.Jobs.Get(id)
+}
+
+// CancelJob cancels the running background job with the given ID.
+func (s *Service) CancelJob(id string) error {
+	return s.Bus.Jobs.Cancel(id)
 }
 
 func (s *Service) HasDescriptor(descriptor string) (bool, error) {
@@ -0,0 +1,25 @@
+package svc
+
+import (
+	"errors"
+
+	"github.com/ledgerhq/satstack/config"
+)
+
+var (
+	// ErrAccountExists indicates that an account being added already exists
+	// in the configuration.
+	ErrAccountExists = config.ErrAccountExists
+
+	// ErrAccountNotFound indicates that no account with the requested ID
+	// exists in the configuration.
+	ErrAccountNotFound = errors.New("account not found")
+
+	// ErrInvalidBlockRange indicates that a range of block heights is
+	// invalid, too large, or goes beyond the tip of the chain.
+	ErrInvalidBlockRange = errors.New("invalid block range")
+
+	// ErrInvalidRescan indicates that the parameters of a rescan request are
+	// invalid.
+	ErrInvalidRescan = errors.New("invalid rescan request")
+)
@@ -41,16 +41,36 @@ func (s *Service) GetStatus() *bus.ExplorerStatus {
 		Pruned:   s.Bus.Pruned,
 		Chain:    s.Bus.Chain,
 		Currency: s.Bus.Currency,
+
+		ScanState:   s.Bus.State.Current(),
+		ScanHistory: s.Bus.State.History(),
+		Warnings:    s.Bus.Warnings(),
 	}
 
 	// Case 1: satstack is running the numbers.
 	// or rescanning the wallet
-	if s.Bus.IsPendingScan {
+	if s.Bus.State.Pending() {
 		status.Status = bus.PendingScan
+
+		// Report the progress of imports and rescans, if bitcoind has
+		// started scanning the wallet.
+		if state := s.Bus.State.Current(); state == bus.StateImporting || state == bus.StateRescanning {
+			if progress := s.scanProgress(); progress != nil {
+				status.Status = bus.Scanning
+				status.ScanProgress = progress
+			}
+		}
+
+		return &status
+	}
+
+	// Case 2: the startup scan was cancelled.
+	if s.Bus.State.Current() == bus.StateIncomplete {
+		status.Status = bus.Incomplete
 		return &status
 	}
 
-	// Case 2: Unable to initialize rpcclient.Client.
+	// Case 3: Unable to initialize rpcclient.Client.
 	client, err := s.Bus.ClientFactory()
 	if err != nil {
 		log.WithField(
@@ -62,7 +82,7 @@ func (s *Service) GetStatus() *bus.ExplorerStatus {
 
 	defer client.Shutdown()
 
-	// Case 3: bitcoind is unreachable - chain RPC failed.
+	// Case 4: bitcoind is unreachable - chain RPC failed.
 	blockChainInfo, err := client.GetBlockChainInfo()
 	if err != nil {
 		log.WithField(
@@ -73,7 +93,11 @@ func (s *Service) GetStatus() *bus.ExplorerStatus {
 		return &status
 	}
 
-	// Case 4: bitcoind is currently catching up on new blocks.
+	if blockChainInfo.Pruned {
+		status.PruneHeight = btcjson.Int64(int64(blockChainInfo.PruneHeight))
+	}
+
+	// Case 5: bitcoind is currently catching up on new blocks.
 	if blockChainInfo.Blocks != blockChainInfo.Headers {
 		status.Status = bus.Syncing
 		status.SyncProgress = btcjson.Float64(
@@ -81,7 +105,7 @@ func (s *Service) GetStatus() *bus.ExplorerStatus {
 		return &status
 	}
 
-	// Case 5: bitcoind is currently importing descriptors
+	// Case 6: bitcoind is currently importing descriptors
 	walletInfo, err := client.GetWalletInfo()
 	if err != nil {
 		log.WithField(
@@ -99,7 +123,7 @@ func (s *Service) GetStatus() *bus.ExplorerStatus {
 		return &status
 	}
 
-	// Case 6: bitcoind is ready to be used with satstack.
+	// Case 7: bitcoind is ready to be used with satstack.
 	status.Status = bus.Ready
 	return &status
 }
@@ -131,3 +155,25 @@ func (s *Service) GetNetwork() (network *bus.Network) {
 	}
 	return network
 }
+
+// scanProgress returns the progress of the wallet scan in bitcoind, as a
+// percentage, or nil if the wallet is not being scanned.
+func (s *Service) scanProgress() *float64 {
+	client, err := s.Bus.ClientFactory()
+	if err != nil {
+		return nil
+	}
+
+	defer client.Shutdown()
+
+	walletInfo, err := client.GetWalletInfo()
+	if err != nil {
+		return nil
+	}
+
+	if v, ok := walletInfo.Scanning.Value.(btcjson.ScanProgress); ok {
+		return btcjson.Float64(v.Progress * 100)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package svc
+
+import (
+	"github.com/ledgerhq/satstack/types"
+)
+
+// GetBlockFilter is a service method to get the compact filter of a block by
+// a string reference.
+func (s *Service) GetBlockFilter(ref string) (*types.BlockFilter, error) {
+	blockHash, err := s.getBlockHashByReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := s.Bus.GetBlockFilter(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := s.Bus.GetBlockHeader(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.BlockFilter{
+		Height:    header.Height,
+		BlockHash: blockHash.String(),
+		Filter:    filter.Filter,
+		Header:    filter.Header,
+	}, nil
+}
+
+// GetBlockFilters is a service method to get the compact filters of the
+// blocks between two heights, both inclusive, in ascending order of height.
+//
+// If withFilters is false, only the filter headers are returned, which can be
+// used by clients to validate the filter header chain.
+func (s *Service) GetBlockFilters(from int64, to int64, withFilters bool) ([]types.BlockFilter, error) {
+	if err := s.checkBlockRange(from, to); err != nil {
+		return nil, err
+	}
+
+	filters := make([]types.BlockFilter, 0, to-from+1)
+	for height := from; height <= to; height++ {
+		blockHash, err := s.Bus.GetBlockHash(height)
+		if err != nil {
+			return nil, err
+		}
+
+		blockFilter := types.BlockFilter{
+			Height:    height,
+			BlockHash: blockHash.String(),
+		}
+
+		if withFilters {
+			filter, err := s.Bus.GetBlockFilter(blockHash)
+			if err != nil {
+				return nil, err
+			}
+
+			blockFilter.Filter, blockFilter.Header = filter.Filter, filter.Header
+		} else {
+			header, err := s.Bus.GetBlockFilterHeader(blockHash)
+			if err != nil {
+				return nil, err
+			}
+
+			blockFilter.Header = header
+		}
+
+		filters = append(filters, blockFilter)
+	}
+
+	return filters, nil
+}
@@ -1,6 +1,8 @@
 package svc
 
 import (
+	"time"
+
 	"github.com/ledgerhq/satstack/bus"
 	"github.com/ledgerhq/satstack/config"
 	"github.com/ledgerhq/satstack/types"
@@ -9,11 +11,19 @@ import (
 type TransactionsService interface {
 	GetTransaction(hash string, block *types.Block, bestBlockHeight int32) (*types.Transaction, error)
 	GetTransactionHex(hash string) (string, error)
+	GetMerkleProof(hash string) (*types.MerkleProof, error)
 	SendTransaction(tx string) (string, error)
 }
 
 type BlocksService interface {
 	GetBlock(ref string) (*types.Block, error)
+	GetBlockRange(from int64, to int64) ([]*types.Block, error)
+	GetBlockHeaders(from int64, to int64) ([]types.BlockHeader, error)
+}
+
+type FiltersService interface {
+	GetBlockFilter(ref string) (*types.BlockFilter, error)
+	GetBlockFilters(from int64, to int64, withFilters bool) ([]types.BlockFilter, error)
 }
 
 type AddressesService interface {
@@ -29,13 +39,26 @@ type ExplorerService interface {
 
 type ControlService interface {
 	HasDescriptor(descriptor string) (bool, error)
-	ImportAccounts(accounts []config.Account)
+	ImportAccounts(accounts []config.Account) string
+	ListJobs() []bus.Job
+	GetJob(id string) (*bus.Job, error)
+	CancelJob(id string) error
+	Rescan(fromHeight *int64, toHeight *int64, fromDate *time.Time, accountID string) (string, error)
+}
+
+type AccountsService interface {
+	ListAccounts() ([]types.Account, error)
+	AddAccounts(accounts []config.Account) (string, error)
+	UpdateAccount(id string, update config.Account) (string, error)
+	RemoveAccount(id string) error
 }
 
 type ServiceInterface interface {
+	AccountsService
 	AddressesService
 	BlocksService
 	ControlService
 	ExplorerService
+	FiltersService
 	TransactionsService
 }
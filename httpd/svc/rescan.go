@@ -0,0 +1,85 @@
+package svc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ledgerhq/satstack/bus"
+	"github.com/ledgerhq/satstack/config"
+)
+
+// Rescan is a service method to rescan the wallet in a background job, and
+// returns the ID of the job.
+//
+// The start of the rescan is given either by a height or by a date, which is
+// mapped to the first block at or after that date. If no end height is given,
+// the rescan runs up to the tip of the chain.
+//
+// If an account ID is given, only the descriptors of that account are
+// rescanned, always up to the tip of the chain.
+func (s *Service) Rescan(fromHeight *int64, toHeight *int64, fromDate *time.Time,
+	accountID string) (string, error) {
+	if s.Bus.State.Pending() {
+		return "", fmt.Errorf("%w (%s)", bus.ErrScanInProgress, s.Bus.State.Current())
+	}
+
+	tip, err := s.Bus.GetBlockCount()
+	if err != nil {
+		return "", err
+	}
+
+	var from int64
+	switch {
+	case fromHeight != nil && fromDate != nil:
+		return "", fmt.Errorf("%w: from_height and from_date are mutually exclusive", ErrInvalidRescan)
+	case fromHeight != nil:
+		from = *fromHeight
+	case fromDate != nil:
+		from, err = s.Bus.GetHeightByTime(*fromDate)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("%w: one of from_height or from_date is required", ErrInvalidRescan)
+	}
+
+	to := tip
+	if toHeight != nil {
+		to = *toHeight
+	}
+
+	if from < 0 || to < from || to > tip {
+		return "", fmt.Errorf("%w: invalid range [%d, %d] (tip at %d)", ErrInvalidRescan, from, to, tip)
+	}
+
+	if accountID == "" {
+		return s.Bus.Jobs.Start(bus.JobRescan, nil, func() error {
+			return s.Bus.Scan(bus.StateRescanning, "rescan requested", func() error {
+				return s.Bus.RescanRange(from, to)
+			})
+		}), nil
+	}
+
+	if toHeight != nil {
+		return "", fmt.Errorf("%w: to_height is not supported for account rescans", ErrInvalidRescan)
+	}
+
+	var account *config.Account
+	for _, a := range s.Config.ActiveAccounts() {
+		if a.ID() == accountID {
+			a := a
+			account = &a
+			break
+		}
+	}
+
+	if account == nil {
+		return "", fmt.Errorf("%w: %s", ErrAccountNotFound, accountID)
+	}
+
+	return s.Bus.Jobs.Start(bus.JobRescan, []string{accountID}, func() error {
+		return s.Bus.Scan(bus.StateRescanning, "account rescan requested", func() error {
+			return s.Bus.RescanAccount(*account, from)
+		})
+	}), nil
+}
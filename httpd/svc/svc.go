@@ -1,7 +1,17 @@
 package svc
 
-import "github.com/ledgerhq/satstack/bus"
+import (
+	"sync"
+
+	"github.com/ledgerhq/satstack/bus"
+	"github.com/ledgerhq/satstack/config"
+)
 
 type Service struct {
-	Bus *bus.Bus
+	Bus    *bus.Bus
+	Config *config.WalletConfig
+
+	// removedAddresses caches the addresses of removed accounts, by account
+	// ID, to filter them out of explorer responses.
+	removedAddresses sync.Map
 }
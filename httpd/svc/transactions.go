@@ -1,8 +1,10 @@
 package svc
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/ledgerhq/satstack/protocol"
 	"github.com/ledgerhq/satstack/types"
 	"github.com/ledgerhq/satstack/utils"
 
@@ -26,6 +28,16 @@ func (s *Service) GetTransaction(hash string, block *types.Block, bestBlockHeigh
 	tx.Block = block
 	buildTx(tx, utxos, bestBlockHeight)
 
+	// The fees cannot be computed from the inputs if some of them spend
+	// outputs that are unknown to the wallet, which is typically the case of
+	// incoming transactions. Fall back to the fees known by bitcoind, if
+	// any.
+	if !hasAllInputs(tx, utxos) {
+		if fees := s.walletFee(hash); fees != nil {
+			tx.Fees = fees
+		}
+	}
+
 	return tx, nil
 }
 
@@ -56,9 +68,13 @@ func (s *Service) buildUTXOs(vin []types.Input) (types.UTXOs, error) {
 			continue
 		}
 
+		if inputRaw.OutputIndex == nil {
+			continue
+		}
+
 		utxoID := types.OutputIdentifier{
 			Hash:  inputRaw.OutputHash,
-			Index: *inputRaw.OutputIndex, // FIXME: can panic
+			Index: *inputRaw.OutputIndex,
 		}
 
 		utxo, err := s.Bus.GetTransaction(utxoID.Hash)
@@ -71,8 +87,16 @@ func (s *Service) buildUTXOs(vin []types.Input) (types.UTXOs, error) {
 			continue
 		}
 
+		if int(utxoID.Index) >= len(utxo.Outputs) || utxo.Outputs[utxoID.Index].Value == nil {
+			log.WithFields(log.Fields{
+				"hash": utxoID.Hash,
+				"vout": utxoID.Index,
+			}).Warn("Input spends a non-existent output")
+			continue
+		}
+
 		utxoMap[utxoID] = types.UTXOData{
-			Value:   *utxo.Outputs[utxoID.Index].Value, // FIXME: can panic
+			Value:   *utxo.Outputs[utxoID.Index].Value,
 			Address: utxo.Outputs[utxoID.Index].Address,
 		}
 	}
@@ -80,6 +104,51 @@ func (s *Service) buildUTXOs(vin []types.Input) (types.UTXOs, error) {
 	return utxoMap, nil
 }
 
+// hasAllInputs returns whether the spent outputs of all the inputs of the
+// transaction are known.
+func hasAllInputs(tx *types.Transaction, utxoMap types.UTXOs) bool {
+	for _, vin := range tx.Inputs {
+		if len(vin.Coinbase) > 0 {
+			continue
+		}
+
+		if vin.OutputIndex == nil {
+			return false
+		}
+
+		utxoID := types.OutputIdentifier{
+			Hash:  vin.OutputHash,
+			Index: *vin.OutputIndex,
+		}
+
+		if _, ok := utxoMap[utxoID]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// walletFee returns the fees of the transaction as known by the wallet, or
+// nil if unknown.
+func (s *Service) walletFee(hash string) *btcutil.Amount {
+	chainHash, err := utils.ParseChainHash(hash)
+	if err != nil {
+		return nil
+	}
+
+	fees, err := s.Bus.GetWalletFee(chainHash)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+			"hash":  hash,
+		}).Debug("Unable to get fees from wallet")
+		return nil
+	}
+
+	return fees
+}
+
 func buildTx(tx *types.Transaction, utxoMap types.UTXOs, bestBlockHeight int32) {
 	sumVinValues := btcutil.Amount(0)
 	vinHasCoinbase := false
@@ -90,12 +159,21 @@ func buildTx(tx *types.Transaction, utxoMap types.UTXOs, bestBlockHeight int32)
 			continue
 		}
 
+		if vin.OutputIndex == nil {
+			continue
+		}
+
 		utxoID := types.OutputIdentifier{
 			Hash:  vin.OutputHash,
 			Index: *vin.OutputIndex,
 		}
 
-		utxo := utxoMap[utxoID]
+		// Leave the address and value of inputs spending unknown outputs
+		// empty, rather than attributing them to an empty address.
+		utxo, ok := utxoMap[utxoID]
+		if !ok {
+			continue
+		}
 
 		tx.Inputs[idx].Address = utxo.Address // mutate the vins in tx
 		tx.Inputs[idx].Value = &utxo.Value
@@ -120,8 +198,9 @@ func buildTx(tx *types.Transaction, utxoMap types.UTXOs, bestBlockHeight int32)
 
 	var fees btcutil.Amount
 
-	if vinHasCoinbase {
-		// Coinbase transactions have no fees
+	if vinHasCoinbase || !hasAllInputs(tx, utxoMap) {
+		// Coinbase transactions have no fees, and the fees of transactions
+		// with unknown inputs cannot be computed.
 		fees = btcutil.Amount(0)
 	} else {
 		fees = sumVinValues - sumVoutValues
@@ -139,3 +218,45 @@ func buildTx(tx *types.Transaction, utxoMap types.UTXOs, bestBlockHeight int32)
 	// Vout values.
 	tx.Amount = &sumVoutValues
 }
+
+// GetMerkleProof is a service function to get a proof of inclusion of a
+// wallet transaction in the block that confirmed it.
+func (s *Service) GetMerkleProof(hash string) (*types.MerkleProof, error) {
+	chainHash, err := utils.ParseChainHash(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	blockHash, err := s.Bus.GetTransactionBlockHash(chainHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if blockHash == nil {
+		return nil, fmt.Errorf("transaction %s is unconfirmed", hash)
+	}
+
+	proofHex, err := s.Bus.GetTxOutProof(chainHash, blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := protocol.DecodeMerkleProof(proofHex)
+	if err != nil {
+		return nil, err
+	}
+
+	if proof.TxID != chainHash.String() {
+		return nil, fmt.Errorf("merkle proof for %s does not match transaction %s",
+			proof.TxID, hash)
+	}
+
+	header, err := s.Bus.GetBlockHeader(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	proof.BlockHeight = header.Height
+
+	return proof, nil
+}
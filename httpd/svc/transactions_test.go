@@ -0,0 +1,117 @@
+package svc
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/ledgerhq/satstack/types"
+)
+
+func TestBuildTxFees(t *testing.T) {
+	index := func(i uint32) *uint32 { return &i }
+	amount := func(a btcutil.Amount) *btcutil.Amount { return &a }
+
+	utxos := types.UTXOs{
+		{Hash: "a", Index: 0}: {Value: 50000, Address: "bc1qknown0"},
+		{Hash: "a", Index: 1}: {Value: 30000, Address: "bc1qknown1"},
+	}
+
+	tests := []struct {
+		name      string
+		inputs    []types.Input
+		outputs   []btcutil.Amount
+		fees      btcutil.Amount
+		addresses []string
+	}{
+		{
+			name: "known inputs",
+			inputs: []types.Input{
+				{OutputHash: "a", OutputIndex: index(0)},
+				{OutputHash: "a", OutputIndex: index(1)},
+			},
+			outputs:   []btcutil.Amount{60000, 19000},
+			fees:      1000,
+			addresses: []string{"bc1qknown0", "bc1qknown1"},
+		},
+		{
+			name: "unknown input",
+			inputs: []types.Input{
+				{OutputHash: "a", OutputIndex: index(0)},
+				{OutputHash: "b", OutputIndex: index(0)},
+			},
+			outputs:   []btcutil.Amount{40000},
+			fees:      0,
+			addresses: []string{"bc1qknown0", ""},
+		},
+		{
+			name: "input without output index",
+			inputs: []types.Input{
+				{OutputHash: "a", OutputIndex: index(0)},
+				{OutputHash: "a"},
+			},
+			outputs:   []btcutil.Amount{40000},
+			fees:      0,
+			addresses: []string{"bc1qknown0", ""},
+		},
+		{
+			name: "outputs above inputs",
+			inputs: []types.Input{
+				{OutputHash: "a", OutputIndex: index(1)},
+			},
+			outputs:   []btcutil.Amount{40000},
+			fees:      0,
+			addresses: []string{"bc1qknown1"},
+		},
+		{
+			name: "coinbase",
+			inputs: []types.Input{
+				{Coinbase: "03a0bb0d"},
+			},
+			outputs:   []btcutil.Amount{625000000},
+			fees:      0,
+			addresses: []string{""},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tx := &types.Transaction{
+				Inputs: test.inputs,
+				Block:  &types.Block{Height: 100},
+			}
+
+			var sumOutputs btcutil.Amount
+			for idx, value := range test.outputs {
+				tx.Outputs = append(tx.Outputs, types.Output{
+					OutputIndex: index(uint32(idx)),
+					Value:       amount(value),
+				})
+				sumOutputs += value
+			}
+
+			buildTx(tx, utxos, 105)
+
+			if *tx.Fees != test.fees {
+				t.Errorf("fees: got %d, want %d", *tx.Fees, test.fees)
+			}
+
+			if *tx.Amount != sumOutputs {
+				t.Errorf("amount: got %d, want %d", *tx.Amount, sumOutputs)
+			}
+
+			if tx.Confirmations != 6 {
+				t.Errorf("confirmations: got %d, want 6", tx.Confirmations)
+			}
+
+			for idx, input := range tx.Inputs {
+				if input.Address != test.addresses[idx] {
+					t.Errorf("input %d: got address %q, want %q", idx, input.Address, test.addresses[idx])
+				}
+
+				if (input.Value != nil) != (test.addresses[idx] != "") {
+					t.Errorf("input %d: unexpected value %v", idx, input.Value)
+				}
+			}
+		})
+	}
+}
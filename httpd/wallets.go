@@ -0,0 +1,129 @@
+package httpd
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ledgerhq/satstack/httpd/svc"
+	log "github.com/sirupsen/logrus"
+)
+
+// apiKeyHeader is the HTTP header used by clients to select the wallet they
+// want to talk to. The api_key query parameter can be used alternatively.
+const apiKeyHeader = "X-API-Key"
+
+// walletRouter dispatches HTTP requests to the router of the wallet they
+// target, when satstack serves more than one wallet.
+//
+// A request is routed, in order of precedence:
+//  1. to the wallet whose API key is passed in the request,
+//  2. to the wallet owning the addresses of an addresses request,
+//  3. to the default wallet.
+//
+// Wallets protected by an API key are never selected by addresses, so that
+// their transactions can't be queried without the key.
+type walletRouter struct {
+	services []*svc.Service
+	engines  []*gin.Engine
+
+	// owners caches the index of the wallet owning an address. Only
+	// positive lookups are cached, as an address may later be imported into
+	// a wallet.
+	owners sync.Map
+}
+
+// GetHandler returns the HTTP handler serving the given wallets. The first
+// service is the default wallet, used when a request doesn't target a
+// specific wallet.
+func GetHandler(services []*svc.Service) http.Handler {
+	if len(services) == 1 {
+		return GetRouter(services[0])
+	}
+
+	r := &walletRouter{services: services}
+	for _, s := range services {
+		r.engines = append(r.engines, GetRouter(s))
+	}
+
+	return r
+}
+
+func (r *walletRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	key := req.Header.Get(apiKeyHeader)
+	if key == "" {
+		key = req.URL.Query().Get("api_key")
+	}
+
+	if key != "" {
+		for i, s := range r.services {
+			if s.Config.APIKey != "" && subtle.ConstantTimeCompare([]byte(s.Config.APIKey), []byte(key)) == 1 {
+				r.engines[i].ServeHTTP(w, req)
+				return
+			}
+		}
+
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+		return
+	}
+
+	if addresses := requestAddresses(req.URL.Path); len(addresses) > 0 {
+		if i, ok := r.walletOwning(addresses); ok {
+			r.engines[i].ServeHTTP(w, req)
+			return
+		}
+	}
+
+	r.engines[0].ServeHTTP(w, req)
+}
+
+// walletOwning returns the index of the first wallet, not protected by an
+// API key, that owns one of the given addresses.
+func (r *walletRouter) walletOwning(addresses []string) (int, bool) {
+	for _, address := range addresses {
+		if i, ok := r.owners.Load(address); ok {
+			return i.(int), true
+		}
+	}
+
+	for _, address := range addresses {
+		for i, s := range r.services {
+			if s.Config.APIKey != "" {
+				continue
+			}
+
+			owned, err := s.Bus.HasAddress(address)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"wallet":  s.Bus.Wallet,
+					"address": address,
+					"error":   err,
+				}).Debug("Failed to lookup address in wallet")
+				continue
+			}
+
+			if owned {
+				r.owners.Store(address, i)
+				return i, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// requestAddresses extracts the addresses from the path of an addresses
+// request, of the form:
+//
+//	/blockchain/:version/:currency/addresses/:addresses/transactions
+func requestAddresses(path string) []string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 6 || parts[0] != "blockchain" || parts[3] != "addresses" ||
+		parts[5] != "transactions" {
+		return nil
+	}
+
+	return strings.Split(parts[4], ",")
+}
@@ -0,0 +1,51 @@
+package protocol
+
+import (
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// ScriptAddresses returns the addresses encoded in the given output script.
+//
+// Standard scripts, including witness v0 and v1 (taproot) outputs, are
+// handled by btcd. Outputs with a witness version from 2 to 16 are not yet
+// standard, but have a well-defined bech32m address (BIP350), which is
+// returned as well. In case of no addresses, the script is non-standard or
+// corrupt.
+func ScriptAddresses(pkScript []byte, params *chaincfg.Params) []string {
+	// Ignore the error here since an error means the script couldn't parse.
+	// In such a case, addrs will be nil.
+	_, addrs, _, _ := txscript.ExtractPkScriptAddrs(pkScript, params)
+
+	result := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		result = append(result, addr.EncodeAddress())
+	}
+
+	if len(result) > 0 || !txscript.IsWitnessProgram(pkScript) {
+		return result
+	}
+
+	version, program, err := txscript.ExtractWitnessProgramInfo(pkScript)
+	if err != nil || version < 2 {
+		return result
+	}
+
+	if address, err := encodeSegWitAddress(params.Bech32HRPSegwit, byte(version), program); err == nil {
+		result = append(result, address)
+	}
+
+	return result
+}
+
+// encodeSegWitAddress encodes a witness program of version 1 or above as a
+// bech32m address.
+func encodeSegWitAddress(hrp string, version byte, program []byte) (string, error) {
+	converted, err := bech32.ConvertBits(program, 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+
+	return bech32.EncodeM(hrp, append([]byte{version}, converted...))
+}
@@ -0,0 +1,76 @@
+package protocol
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestScriptAddresses(t *testing.T) {
+	// Test vectors of BIP173 and BIP350.
+	tests := []struct {
+		name     string
+		script   string
+		params   *chaincfg.Params
+		expected []string
+	}{
+		{
+			name:     "witness v0 keyhash",
+			script:   "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+			params:   &chaincfg.MainNetParams,
+			expected: []string{"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		},
+		{
+			name:     "witness v1 taproot",
+			script:   "512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+			params:   &chaincfg.MainNetParams,
+			expected: []string{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		},
+		{
+			name:     "witness v1 taproot testnet",
+			script:   "5120000000c4a5cad46221b2a187905e5266362b99d5e91c6ce24d165dab93e86433",
+			params:   &chaincfg.TestNet3Params,
+			expected: []string{"tb1pqqqqp399et2xygdj5xreqhjjvcmzhxw4aywxecjdzew6hylgvsesf3hn0c"},
+		},
+		{
+			name:     "witness v2",
+			script:   "5210751e76e8199196d454941c45d1b3a323",
+			params:   &chaincfg.MainNetParams,
+			expected: []string{"bc1zw508d6qejxtdg4y5r3zarvaryvaxxpcs"},
+		},
+		{
+			name:     "witness v16",
+			script:   "6002751e",
+			params:   &chaincfg.MainNetParams,
+			expected: []string{"bc1sw50qgdz25j"},
+		},
+		{
+			name:     "null data",
+			script:   "6a0b68656c6c6f20776f726c64",
+			params:   &chaincfg.MainNetParams,
+			expected: []string{},
+		},
+		{
+			name:     "invalid witness program",
+			script:   "5101",
+			params:   &chaincfg.MainNetParams,
+			expected: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			script, err := hex.DecodeString(test.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			addresses := ScriptAddresses(script, test.params)
+			if !reflect.DeepEqual(addresses, test.expected) {
+				t.Errorf("got %v, want %v", addresses, test.expected)
+			}
+		})
+	}
+}
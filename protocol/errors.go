@@ -9,4 +9,25 @@ var (
 	// ErrMsgTxDeserialize indicates that the parser could not process the
 	// serialized hex to wire.MsgTx.
 	ErrMsgTxDeserialize = errors.New("failed to deserialize to MsgTx")
+
+	// ErrMerkleBlockDeserialize indicates that the parser could not process
+	// the serialized hex to wire.MsgMerkleBlock.
+	ErrMerkleBlockDeserialize = errors.New("failed to deserialize to MsgMerkleBlock")
+
+	// ErrMerkleProofIncomplete indicates that the partial merkle tree ran out
+	// of hashes or flag bits before it could be fully traversed.
+	ErrMerkleProofIncomplete = errors.New("incomplete merkle proof")
+
+	// ErrMerkleProofMalformed indicates that the partial merkle tree has
+	// unused hashes or flag bits, or identical sibling nodes, which could be
+	// used to forge a proof (CVE-2012-2459).
+	ErrMerkleProofMalformed = errors.New("malformed merkle proof")
+
+	// ErrMerkleProofMatches indicates that the partial merkle tree does not
+	// prove exactly one transaction.
+	ErrMerkleProofMatches = errors.New("unexpected number of matches in merkle proof")
+
+	// ErrMerkleRootMismatch indicates that the merkle root computed from a
+	// partial merkle tree does not match the one in the block header.
+	ErrMerkleRootMismatch = errors.New("merkle root mismatch")
 )
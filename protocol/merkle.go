@@ -0,0 +1,200 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ledgerhq/satstack/types"
+)
+
+// merkleNode identifies a node in a partial merkle tree by its height (0 for
+// the leaves) and its position from the left at that height.
+type merkleNode struct {
+	height uint32
+	pos    uint32
+}
+
+// partialMerkleTree is a helper to traverse the partial merkle tree encoded
+// in a wire.MsgMerkleBlock, as described in BIP-0037.
+type partialMerkleTree struct {
+	numTx  uint32
+	hashes []*chainhash.Hash
+	flags  []byte
+
+	hashIdx uint32
+	bitIdx  uint32
+
+	// nodes holds the hash of every node visited during the traversal.
+	nodes map[merkleNode]chainhash.Hash
+
+	// matches holds the leaf positions of the matched transactions.
+	matches []uint32
+}
+
+// DecodeMerkleProof decodes the serialized merkle block returned by the
+// gettxoutproof RPC, and returns the merkle branch and position of the
+// proven transaction.
+//
+// The merkle root computed from the branch is checked against the one in the
+// block header. The BlockHeight field of the returned value is not populated.
+func DecodeMerkleProof(proofHex string) (*types.MerkleProof, error) {
+	serialized, err := hex.DecodeString(proofHex)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrDecodeHex, err)
+	}
+
+	var msg wire.MsgMerkleBlock
+	if err := msg.BtcDecode(bytes.NewReader(serialized), wire.ProtocolVersion,
+		wire.BaseEncoding); err != nil {
+		return nil, fmt.Errorf("%s: %w", ErrMerkleBlockDeserialize, err)
+	}
+
+	tree := &partialMerkleTree{
+		numTx:  msg.Transactions,
+		hashes: msg.Hashes,
+		flags:  msg.Flags,
+		nodes:  make(map[merkleNode]chainhash.Hash),
+	}
+
+	treeHeight := tree.height()
+	root, err := tree.traverse(treeHeight, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// As in bitcoind, every hash must be used, and only the padding bits of
+	// the last flag byte may be left over.
+	if tree.hashIdx != uint32(len(tree.hashes)) {
+		return nil, fmt.Errorf("%s: %d unused hashes", ErrMerkleProofMalformed,
+			uint32(len(tree.hashes))-tree.hashIdx)
+	}
+
+	if (tree.bitIdx+7)/8 != uint32(len(tree.flags)) {
+		return nil, fmt.Errorf("%s: unused flag bits", ErrMerkleProofMalformed)
+	}
+
+	if !root.IsEqual(&msg.Header.MerkleRoot) {
+		return nil, ErrMerkleRootMismatch
+	}
+
+	if len(tree.matches) != 1 {
+		return nil, fmt.Errorf("%s: %d matched transactions",
+			ErrMerkleProofMatches, len(tree.matches))
+	}
+
+	pos := tree.matches[0]
+	leaf := tree.nodes[merkleNode{height: 0, pos: pos}]
+
+	branch := make([]string, 0, treeHeight)
+	for height := uint32(0); height < treeHeight; height++ {
+		siblingPos := (pos >> height) ^ 1
+		if siblingPos >= tree.width(height) {
+			// The node has no sibling, and is hashed with itself.
+			siblingPos = pos >> height
+		}
+
+		sibling, ok := tree.nodes[merkleNode{height: height, pos: siblingPos}]
+		if !ok {
+			return nil, ErrMerkleProofIncomplete
+		}
+
+		branch = append(branch, sibling.String())
+	}
+
+	var header bytes.Buffer
+	if err := msg.Header.Serialize(&header); err != nil {
+		return nil, err
+	}
+
+	return &types.MerkleProof{
+		TxID:      leaf.String(),
+		BlockHash: msg.Header.BlockHash().String(),
+		Header:    hex.EncodeToString(header.Bytes()),
+		Merkle:    branch,
+		Position:  pos,
+	}, nil
+}
+
+// SerializeBlockHeader returns the hex-encoded 80-byte serialization of the
+// given block header.
+func SerializeBlockHeader(header *wire.BlockHeader) (string, error) {
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// width returns the number of nodes in the tree at the given height.
+func (t *partialMerkleTree) width(height uint32) uint32 {
+	return (t.numTx + (1 << height) - 1) >> height
+}
+
+// height returns the height of the root of the tree.
+func (t *partialMerkleTree) height() uint32 {
+	var height uint32
+	for t.width(height) > 1 {
+		height++
+	}
+
+	return height
+}
+
+// traverse computes the hash of the node at the given height and position,
+// by consuming flag bits and hashes in depth-first order.
+func (t *partialMerkleTree) traverse(height uint32, pos uint32) (*chainhash.Hash, error) {
+	if t.bitIdx >= uint32(len(t.flags))*8 {
+		return nil, ErrMerkleProofIncomplete
+	}
+
+	flag := t.flags[t.bitIdx/8]&(1<<(t.bitIdx%8)) != 0
+	t.bitIdx++
+
+	if height == 0 || !flag {
+		if t.hashIdx >= uint32(len(t.hashes)) {
+			return nil, ErrMerkleProofIncomplete
+		}
+
+		hash := *t.hashes[t.hashIdx]
+		t.hashIdx++
+
+		if height == 0 && flag {
+			t.matches = append(t.matches, pos)
+		}
+
+		t.nodes[merkleNode{height: height, pos: pos}] = hash
+		return &hash, nil
+	}
+
+	left, err := t.traverse(height-1, pos*2)
+	if err != nil {
+		return nil, err
+	}
+
+	right := left
+	if pos*2+1 < t.width(height-1) {
+		right, err = t.traverse(height-1, pos*2+1)
+		if err != nil {
+			return nil, err
+		}
+
+		// Identical siblings allow different transaction lists to have the
+		// same merkle root (CVE-2012-2459).
+		if right.IsEqual(left) {
+			return nil, fmt.Errorf("%s: identical sibling nodes", ErrMerkleProofMalformed)
+		}
+	}
+
+	var buf [chainhash.HashSize * 2]byte
+	copy(buf[:chainhash.HashSize], left[:])
+	copy(buf[chainhash.HashSize:], right[:])
+
+	hash := chainhash.DoubleHashH(buf[:])
+	t.nodes[merkleNode{height: height, pos: pos}] = hash
+
+	return &hash, nil
+}
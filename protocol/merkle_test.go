@@ -0,0 +1,233 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/ledgerhq/satstack/types"
+)
+
+// Output of gettxoutproof for the second transaction of mainnet block
+// 100000, which has four transactions.
+const (
+	block100000Proof = "0100000050120119172a610421a6c3011dd330d9df07b63616c2cc1f1cd002000000000066" +
+		"57a9252aacd5c0b2940996ecff952228c3067cc38d4885efb5a4ac4247e9f337221b4d4c86041b0f2b5710" +
+		"0400000003876dd0a3ef4a2816ffd1c12ab649825a958b0ff3bb3d6f3e1250f13ddbf0148cc40297f730dd" +
+		"7b5a99567eb8d27b78758f607507c52292d02d4031895b52f2ff49aef42d78e3e9999c9e6ec9e1dddd6cb8" +
+		"80bf3b076a03be1318ca789089308e010b"
+
+	block100000Hash   = "000000000003ba27aa200b1cecaad478d2b00432346c3f1f3986da1afd33e506"
+	block100000Header = "0100000050120119172a610421a6c3011dd330d9df07b63616c2cc1f1cd00200000000006657a" +
+		"9252aacd5c0b2940996ecff952228c3067cc38d4885efb5a4ac4247e9f337221b4d4c86041b0f2b5710"
+)
+
+// Transactions of mainnet block 100000.
+var block100000Txs = []string{
+	"8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87",
+	"fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4",
+	"6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4",
+	"e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d",
+}
+
+func mustHash(t *testing.T, s string) *chainhash.Hash {
+	t.Helper()
+
+	hash, err := chainhash.NewHashFromStr(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+// hashPair returns the hash of the parent of the given merkle nodes.
+func hashPair(left *chainhash.Hash, right *chainhash.Hash) *chainhash.Hash {
+	var buf [chainhash.HashSize * 2]byte
+	copy(buf[:chainhash.HashSize], left[:])
+	copy(buf[chainhash.HashSize:], right[:])
+
+	hash := chainhash.DoubleHashH(buf[:])
+	return &hash
+}
+
+// mutateProof decodes the given proof, applies the given function to it, and
+// returns the encoded result.
+func mutateProof(t *testing.T, proof string, mutate func(t *testing.T, msg *wire.MsgMerkleBlock)) string {
+	t.Helper()
+
+	serialized, err := hex.DecodeString(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg wire.MsgMerkleBlock
+	if err := msg.BtcDecode(bytes.NewReader(serialized), wire.ProtocolVersion,
+		wire.BaseEncoding); err != nil {
+		t.Fatal(err)
+	}
+
+	mutate(t, &msg)
+
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+		t.Fatal(err)
+	}
+
+	return hex.EncodeToString(buf.Bytes())
+}
+
+func TestDecodeMerkleProof(t *testing.T) {
+	tests := []struct {
+		name     string
+		proof    string
+		mutate   func(t *testing.T, msg *wire.MsgMerkleBlock)
+		expected *types.MerkleProof
+		err      error
+	}{
+		{
+			name:  "gettxoutproof",
+			proof: block100000Proof,
+			expected: &types.MerkleProof{
+				TxID:      block100000Txs[1],
+				BlockHash: block100000Hash,
+				Header:    block100000Header,
+				Merkle: []string{
+					block100000Txs[0],
+					hashPair(mustHash(t, block100000Txs[2]), mustHash(t, block100000Txs[3])).String(),
+				},
+				Position: 1,
+			},
+		},
+		{
+			name:  "last transaction",
+			proof: block100000Proof,
+			mutate: func(t *testing.T, msg *wire.MsgMerkleBlock) {
+				msg.Hashes = []*chainhash.Hash{
+					hashPair(mustHash(t, block100000Txs[0]), mustHash(t, block100000Txs[1])),
+					mustHash(t, block100000Txs[2]),
+					mustHash(t, block100000Txs[3]),
+				}
+				msg.Flags = []byte{0x15}
+			},
+			expected: &types.MerkleProof{
+				TxID:      block100000Txs[3],
+				BlockHash: block100000Hash,
+				Header:    block100000Header,
+				Merkle: []string{
+					block100000Txs[2],
+					hashPair(mustHash(t, block100000Txs[0]), mustHash(t, block100000Txs[1])).String(),
+				},
+				Position: 3,
+			},
+		},
+		{
+			name:  "trailing unused hash",
+			proof: block100000Proof,
+			mutate: func(t *testing.T, msg *wire.MsgMerkleBlock) {
+				msg.Hashes = append(msg.Hashes, mustHash(t, block100000Txs[2]))
+			},
+			err: ErrMerkleProofMalformed,
+		},
+		{
+			name:  "unused flag bits",
+			proof: block100000Proof,
+			mutate: func(t *testing.T, msg *wire.MsgMerkleBlock) {
+				msg.Flags = append(msg.Flags, 0x00)
+			},
+			err: ErrMerkleProofMalformed,
+		},
+		{
+			name:  "missing hash",
+			proof: block100000Proof,
+			mutate: func(t *testing.T, msg *wire.MsgMerkleBlock) {
+				msg.Hashes = msg.Hashes[:len(msg.Hashes)-1]
+			},
+			err: ErrMerkleProofIncomplete,
+		},
+		{
+			// The block with its last transaction duplicated has the same
+			// merkle root as the block without (CVE-2012-2459).
+			name:  "duplicated siblings",
+			proof: block100000Proof,
+			mutate: func(t *testing.T, msg *wire.MsgMerkleBlock) {
+				h01 := hashPair(mustHash(t, block100000Txs[0]), mustHash(t, block100000Txs[1]))
+				h2 := mustHash(t, block100000Txs[2])
+
+				msg.Header.MerkleRoot = *hashPair(h01, hashPair(h2, h2))
+				msg.Hashes = []*chainhash.Hash{h01, h2, h2}
+				msg.Flags = []byte{0x15}
+			},
+			err: ErrMerkleProofMalformed,
+		},
+		{
+			name:  "merkle root mismatch",
+			proof: block100000Proof,
+			mutate: func(t *testing.T, msg *wire.MsgMerkleBlock) {
+				msg.Header.MerkleRoot = chainhash.Hash{}
+			},
+			err: ErrMerkleRootMismatch,
+		},
+		{
+			name:  "no matched transaction",
+			proof: block100000Proof,
+			mutate: func(t *testing.T, msg *wire.MsgMerkleBlock) {
+				msg.Hashes = []*chainhash.Hash{&msg.Header.MerkleRoot}
+				msg.Flags = []byte{0x00}
+			},
+			err: ErrMerkleProofMatches,
+		},
+		{
+			name:  "truncated",
+			proof: block100000Proof[:len(block100000Proof)-2],
+			err:   ErrMerkleBlockDeserialize,
+		},
+		{
+			name:  "truncated header",
+			proof: block100000Proof[:100],
+			err:   ErrMerkleBlockDeserialize,
+		},
+		{
+			name:  "invalid hex",
+			proof: "zz" + block100000Proof[2:],
+			err:   ErrDecodeHex,
+		},
+		{
+			name:  "odd length hex",
+			proof: block100000Proof[:len(block100000Proof)-1],
+			err:   ErrDecodeHex,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proof := tt.proof
+			if tt.mutate != nil {
+				proof = mutateProof(t, proof, tt.mutate)
+			}
+
+			actual, err := DecodeMerkleProof(proof)
+
+			// Errors are wrapped with their cause as text, so they are
+			// matched by prefix.
+			if tt.err != nil {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err.Error()) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("got %+v, want %+v", actual, tt.expected)
+			}
+		})
+	}
+}
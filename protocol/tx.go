@@ -11,7 +11,6 @@ import (
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/ledgerhq/satstack/types"
 )
@@ -38,8 +37,11 @@ func ParseVerboseTransaction(txRaw *btcjson.TxRawResult) *types.Transaction {
 	var outputs []types.Output
 	for _, output := range txRaw.Vout {
 		val := utils.ParseSatoshi(output.Value)
-		var addr string
-		if addrs := output.ScriptPubKey.Addresses; len(addrs) > 0 {
+
+		// Bitcoin Core 22+ reports a single address, including for witness
+		// v1 outputs, and no longer reports the deprecated addresses list.
+		addr := output.ScriptPubKey.Address
+		if addrs := output.ScriptPubKey.Addresses; addr == "" && len(addrs) > 0 {
 			addr = addrs[0]
 		}
 
@@ -56,7 +58,7 @@ func ParseVerboseTransaction(txRaw *btcjson.TxRawResult) *types.Transaction {
 		Hash:     txRaw.Hash,
 		LockTime: txRaw.LockTime,
 		Inputs:   inputs,
-		Outputs:  nil,
+		Outputs:  outputs,
 	}
 }
 
@@ -137,22 +139,15 @@ func createVoutList(mtx *wire.MsgTx, chainParams *chaincfg.Params) []types.Outpu
 		vout.Value = &value
 		vout.ScriptHex = hex.EncodeToString(v.PkScript)
 
-		// Ignore the error here since an error means the script
-		// couldn't parse. In such a case, addrs will be nil.
-		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(
-			v.PkScript, chainParams)
-
-		// Encode the addresses to to string.
-		encodedAddrs := make([]string, len(addrs))
-		for j, addr := range addrs {
-			encodedAddr := addr.EncodeAddress()
-			encodedAddrs[j] = encodedAddr
-		}
+		encodedAddrs := ScriptAddresses(v.PkScript, chainParams)
 
-		// ScriptPubKey can have multiple addresses for multisig transactions.
+		// ScriptPubKey can have multiple addresses for bare multisig
+		// transactions, one per public key.
 		//
 		// We pick the first address in the list, which is what libcore
-		// expects. Caution: may have side-effects.
+		// expects. Caution: may have side-effects. Multisig accounts use
+		// P2SH or P2WSH outputs instead, which have a single address, so that
+		// their outputs are always attributed to them.
 		//
 		// In case of no addresses, the Address field is not populated.
 		// Generally, this means the ScriptPubKey is corrupt.
@@ -8,6 +8,7 @@ NC='\033[0m' # No Color
 pkh="^pkh\(.*([xt]pub[a-zA-Z0-9]+).*"
 wpkh="^wpkh\(.*([xt]pub[a-zA-Z0-9]+).*"
 sh_wpkh="^sh\(wpkh\(.*([xt]pub[a-zA-Z0-9]+).*"
+tr="^tr\(.*([xt]pub[a-zA-Z0-9]+).*"
 
 
 for descriptor in $(jq -r ".accounts[] | [.external] | @csv" < lss.json | sed "s/\"//g")
@@ -21,6 +22,9 @@ do
     elif [[ $descriptor =~ $wpkh ]]; then
       xpub="${BASH_REMATCH[1]}"
       scheme="native_segwit"
+    elif [[ $descriptor =~ $tr ]]; then
+      xpub="${BASH_REMATCH[1]}"
+      scheme="taproot"
     else
       exit 1
     fi
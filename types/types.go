@@ -41,11 +41,21 @@ type Output struct {
 // Block models data corresponding to a block, but with limited information.
 // It is used to represent minimal information of the block containing the given
 // transaction.
+//
+// Fields marked as (?) are optional, and only populated when the block is
+// queried directly, rather than as part of a transaction.
 type Block struct {
-	Hash         string    `json:"hash"`          // 0x prefixed
-	Height       int64     `json:"height"`        // integer
-	Time         string    `json:"time"`          // RFC3339 format
-	Transactions *[]string `json:"txs,omitempty"` // optional list of 0x prefixed transaction IDs
+	Hash         string    `json:"hash"`                    // 0x prefixed
+	Height       int64     `json:"height"`                  // integer
+	Time         string    `json:"time"`                    // RFC3339 format
+	Transactions *[]string `json:"txs,omitempty"`           // optional list of 0x prefixed transaction IDs
+	PreviousHash string    `json:"previous_hash,omitempty"` // (?) hash of the previous block in the chain
+	MerkleRoot   string    `json:"merkle_root,omitempty"`   // (?) root of the transaction merkle tree
+	Bits         string    `json:"bits,omitempty"`          // (?) hex-encoded compact target
+	Difficulty   float64   `json:"difficulty,omitempty"`    // (?) proof-of-work difficulty
+	Size         int32     `json:"size,omitempty"`          // (?) block size in bytes
+	Weight       int32     `json:"weight,omitempty"`        // (?) block weight as defined in BIP141
+	MedianTime   string    `json:"median_time,omitempty"`   // (?) median time past, in RFC3339 format
 }
 
 // BlockWithTransactions is a struct that embeds Block, but also contains
@@ -73,3 +83,54 @@ type Addresses struct {
 	Truncated    bool          `json:"truncated"`
 	Transactions []Transaction `json:"txs"`
 }
+
+// BlockHeader models a serialized block header, along with its position in
+// the chain.
+type BlockHeader struct {
+	Height int64  `json:"height"` // integer
+	Hash   string `json:"hash"`   // 0x prefixed
+	Hex    string `json:"hex"`    // hex-encoded 80-byte header
+}
+
+// MerkleProof models a proof of inclusion of a transaction in a block, that
+// can be verified against the merkle root of the block header.
+type MerkleProof struct {
+	TxID        string   `json:"txid"`         // transaction being proven
+	BlockHash   string   `json:"block_hash"`   // hash of the block containing the transaction
+	BlockHeight int64    `json:"block_height"` // height of the block containing the transaction
+	Header      string   `json:"header"`       // hex-encoded 80-byte header of the block
+	Merkle      []string `json:"merkle"`       // merkle branch, from the leaf to the root
+	Position    uint32   `json:"pos"`          // index of the transaction in the block
+}
+
+// BlockFilter models a BIP-0158 compact block filter, along with its BIP-0157
+// filter header.
+type BlockFilter struct {
+	Height    int64  `json:"height"`           // integer
+	BlockHash string `json:"block_hash"`       // 0x prefixed
+	Filter    string `json:"filter,omitempty"` // hex-encoded basic filter; omitted in filter header chains
+	Header    string `json:"header"`           // hex-encoded filter header
+}
+
+// Account models an account imported in the SatStack wallet, as returned by
+// the control API.
+type Account struct {
+	ID         string      `json:"id"`                  // stable identifier derived from the external descriptor
+	External   string      `json:"external"`            // output descriptor at external path
+	Internal   string      `json:"internal"`            // output descriptor at internal path
+	ScriptType string      `json:"script_type"`         // script expressions of the descriptors, like wpkh or wsh(sortedmulti)
+	Threshold  int         `json:"threshold,omitempty"` // [multisig] number of required signatures
+	Keys       []KeyOrigin `json:"keys"`                // extended public keys, one per cosigner for multisig accounts
+	Depth      int         `json:"depth"`               // number of imported addresses
+	Birthday   string      `json:"birthday,omitempty"`  // earliest known creation date (YYYY/MM/DD)
+	Removed    bool        `json:"removed"`             // whether the account was removed by the user
+	Status     string      `json:"status"`              // one of "imported", "importing", "not-imported" or "removed"
+}
+
+// KeyOrigin models an extended public key of an account, along with the
+// master key fingerprint and derivation path it originates from.
+type KeyOrigin struct {
+	Fingerprint string `json:"fingerprint,omitempty"` // master key fingerprint in hex; omitted if unknown
+	Path        string `json:"path,omitempty"`        // derivation path, like m/48'/0'/0'/2'; omitted if unknown
+	XPub        string `json:"xpub"`                  // extended public key
+}